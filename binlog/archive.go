@@ -0,0 +1,254 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/storage"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// the manifest is kept in the storage beside the archived binlog files
+const manifestName = "manifest.json"
+
+// ArchivedSegment is a binlog file in the archive
+type ArchivedSegment struct {
+	Index uint64 `json:"index"`
+	Size  int64  `json:"size"`
+	// CRC is the crc32c of the whole binlog file
+	CRC         uint32    `json:"crc"`
+	Entries     int64     `json:"entries"`
+	MinCommitTs int64     `json:"min-commit-ts"`
+	MaxCommitTs int64     `json:"max-commit-ts"`
+	ArchiveTime time.Time `json:"archive-time"`
+}
+
+// Manifest lists the archived binlog files in the order of their indexes
+type Manifest struct {
+	Segments []ArchivedSegment `json:"segments"`
+}
+
+// LoadManifest reads the manifest of the storage,
+// it's empty if nothing is archived yet
+func LoadManifest(st storage.Storage) (*Manifest, error) {
+	r, err := st.Get(manifestName)
+	if err == storage.ErrNotExist {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	m := &Manifest{}
+	if err = json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("binlog: bad manifest: %v", err)
+	}
+	return m, nil
+}
+
+func saveManifest(st storage.Storage, m *Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return st.Put(manifestName, bytes.NewReader(data))
+}
+
+func (m *Manifest) search(index uint64) (int, bool) {
+	i := sort.Search(len(m.Segments), func(i int) bool { return m.Segments[i].Index >= index })
+	return i, i < len(m.Segments) && m.Segments[i].Index == index
+}
+
+// archiveSegment uploads the sealed binlog file and adds it to the
+// manifest, the file is uploaded before it's listed in the manifest
+func archiveSegment(st storage.Storage, dir string, index uint64, opts *Options) error {
+	meta, err := loadSegmentMeta(dir, index, false, opts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path.Join(dir, fileName(index)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size, crc, err := fileCRC(f)
+	if err != nil {
+		return err
+	}
+
+	m, err := LoadManifest(st)
+	if err != nil {
+		return err
+	}
+
+	i, found := m.search(index)
+	if found && m.Segments[i].Size == size && m.Segments[i].CRC == crc {
+		return nil
+	}
+
+	if _, err = f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	if err = st.Put(fileName(index), f); err != nil {
+		return err
+	}
+
+	seg := ArchivedSegment{
+		Index:       index,
+		Size:        size,
+		CRC:         crc,
+		Entries:     meta.Entries,
+		MinCommitTs: meta.MinCommitTs,
+		MaxCommitTs: meta.MaxCommitTs,
+		ArchiveTime: time.Now(),
+	}
+	if found {
+		m.Segments[i] = seg
+	} else {
+		m.Segments = append(m.Segments, ArchivedSegment{})
+		copy(m.Segments[i+1:], m.Segments[i:])
+		m.Segments[i] = seg
+	}
+
+	if err = saveManifest(st, m); err != nil {
+		return err
+	}
+
+	opts.Logger.Infof("archived binlog file %s", path.Join(dir, fileName(index)))
+	return nil
+}
+
+// Restore downloads the archived binlog files into the directory, from
+// the first one that may hold the entries of fromTs to the last one, so
+// the directory can be read by Open and OpenAtCommitTs from fromTs. the
+// files already there with the same size and crc are kept. opts may be nil
+func Restore(st storage.Storage, dirpath string, fromTs int64, opts *Options) ([]string, error) {
+	opts = opts.normalize()
+
+	m, err := LoadManifest(st)
+	if err != nil {
+		return nil, err
+	}
+
+	segs := m.Segments
+	for len(segs) > 0 && segs[0].MaxCommitTs < fromTs {
+		segs = segs[1:]
+	}
+	if len(segs) == 0 {
+		return nil, ErrFileNotFound
+	}
+
+	// the readers follow the binlog files by their indexes one by one
+	for i := 1; i < len(segs); i++ {
+		if segs[i].Index != segs[i-1].Index+1 {
+			return nil, fmt.Errorf("binlog: archived binlog file %s is missing", fileName(segs[i-1].Index+1))
+		}
+	}
+
+	if err = os.MkdirAll(dirpath, fileutil.PrivateDirMode); err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	for _, seg := range segs {
+		name := fileName(seg.Index)
+		same, err := sameSegment(path.Join(dirpath, name), seg)
+		if err != nil {
+			return restored, err
+		}
+		if same {
+			continue
+		}
+
+		if err = restoreSegment(st, dirpath, seg, opts); err != nil {
+			return restored, err
+		}
+
+		opts.Logger.Infof("restored binlog file %s", path.Join(dirpath, name))
+		restored = append(restored, name)
+	}
+
+	return restored, nil
+}
+
+// restoreSegment downloads the binlog file to a temporary file, checks
+// it against the manifest and renames it
+func restoreSegment(st storage.Storage, dir string, seg ArchivedSegment, opts *Options) error {
+	name := fileName(seg.Index)
+	r, err := st.Get(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	p := path.Join(dir, name)
+	f, err := os.OpenFile(p+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, opts.FileMode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(p + ".tmp")
+
+	h := crc32.New(crcTable)
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err == nil && (size != seg.Size || h.Sum32() != seg.CRC) {
+		err = fmt.Errorf("binlog: archived binlog file %s doesn't match the manifest", name)
+	}
+	if err == nil {
+		err = fileutil.Fsync(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the sidecar files are rebuilt from the binlog file when they are read
+	for _, sidecar := range []string{metaName(seg.Index), indexName(seg.Index)} {
+		if err = os.Remove(path.Join(dir, sidecar)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+// sameSegment returns whether the binlog file at p is the archived one,
+// it's false if there is no such file
+func sameSegment(p string, seg ArchivedSegment) (bool, error) {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	size, crc, err := fileCRC(f)
+	if err != nil {
+		return false, err
+	}
+	return size == seg.Size && crc == seg.CRC, nil
+}
+
+// fileCRC returns the size and crc32c of the whole file from where r is
+func fileCRC(r io.Reader) (int64, uint32, error) {
+	h := crc32.New(crcTable)
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, h.Sum32(), nil
+}
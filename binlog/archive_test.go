@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/binlog/storage"
+)
+
+// memStorage keeps the objects in memory, so the tests can corrupt them
+type memStorage struct {
+	mu   sync.Mutex
+	objs map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objs: make(map[string][]byte)}
+}
+
+func (s *memStorage) Put(name string, r io.ReadSeeker) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objs[name] = data
+	return nil
+}
+
+func (s *memStorage) Get(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.objs[name]
+	if !ok {
+		return nil, storage.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// archiveTestBinlog writes 100 entries in small files
+// and purges all but the tail into the storage
+func archiveTestBinlog(t *testing.T, st storage.Storage) []string {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	opts.Archive = st
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for i := 0; i < 100; i++ {
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := b.GC(GCPolicy{SafeCommitTs: 1000})
+	if err != nil || len(purged) == 0 {
+		t.Fatalf("purged %v, %v", purged, err)
+	}
+	return purged
+}
+
+func TestArchiveRestore(t *testing.T) {
+	local, err := storage.NewLocalStorage(path.Join(t.TempDir(), "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, st := range []storage.Storage{local, newMemStorage()} {
+		purged := archiveTestBinlog(t, st)
+		m, err := LoadManifest(st)
+		if err != nil || len(m.Segments) != len(purged) {
+			t.Fatalf("manifest %+v, %v, purged %v", m, err, purged)
+		}
+
+		dir := path.Join(t.TempDir(), "restore")
+		restored, err := Restore(st, dir, 30, nil)
+		if err != nil || len(restored) == 0 {
+			t.Fatalf("restored %v, %v", restored, err)
+		}
+
+		r, err := OpenAtCommitTs(dir, 30, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ents, err := r.Read(1000)
+		r.Close()
+		last := m.Segments[len(m.Segments)-1].MaxCommitTs
+		if err != io.EOF || len(ents) == 0 || ents[0].CommitTs != 30 || ents[len(ents)-1].CommitTs != last {
+			t.Fatalf("read %d entries from the restored files, %v", len(ents), err)
+		}
+
+		if v, err := Verify(dir, nil); err != nil || !v.OK() {
+			t.Fatalf("verify the restored files %+v, %v", v, err)
+		}
+
+		// the files already restored are kept
+		if restored, err = Restore(st, dir, 30, nil); err != nil || len(restored) != 0 {
+			t.Fatalf("restored %v again, %v", restored, err)
+		}
+	}
+}
+
+func TestRestoreReplacesChangedFile(t *testing.T) {
+	st := newMemStorage()
+	archiveTestBinlog(t, st)
+
+	dir := path.Join(t.TempDir(), "restore")
+	if _, err := Restore(st, dir, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a flipped byte keeps the size but not the crc
+	writeAt(t, dir, 0, segmentHeaderBytes+frameHeaderBytes, []byte{'!'})
+	restored, err := Restore(st, dir, 0, nil)
+	if err != nil || len(restored) != 1 || restored[0] != fileName(0) {
+		t.Fatalf("restored %v, %v, want %s", restored, err, fileName(0))
+	}
+	if v, err := Verify(dir, nil); err != nil || !v.OK() {
+		t.Fatalf("verify the restored files %+v, %v", v, err)
+	}
+}
+
+func TestRestoreCorruptedArchive(t *testing.T) {
+	st := newMemStorage()
+	archiveTestBinlog(t, st)
+
+	obj := st.objs[fileName(0)]
+	obj[len(obj)/2] ^= 0xff
+
+	dir := path.Join(t.TempDir(), "restore")
+	if _, err := Restore(st, dir, 0, nil); err == nil {
+		t.Fatal("restored a corrupted archived file")
+	}
+	if names, _ := readBinlogNames(dir, DefaultOptions().Logger); len(names) != 0 {
+		t.Fatalf("the corrupted file is restored as %v", names)
+	}
+}
@@ -0,0 +1,72 @@
+package binlog
+
+// the smallest block an Arena allocates
+const minArenaBytes = 64 * 1024
+
+// Arena is the reusable memory that ReadInto decodes the entries into. the
+// payloads of the decoded entries point into it, so they are only valid
+// until the Arena is passed to the next ReadInto. it grows to fit the
+// largest batch read, after which reading doesn't allocate any more.
+// the zero value is ready to use, an Arena must not be shared by the
+// readers running concurrently
+type Arena struct {
+	buf []byte
+	off int
+}
+
+// NewArena returns an Arena which is able to hold size bytes of entries
+// before it grows
+func NewArena(size int) *Arena {
+	return &Arena{buf: make([]byte, size)}
+}
+
+func (a *Arena) reset() {
+	if a != nil {
+		a.off = 0
+	}
+}
+
+// alloc returns n bytes of the arena. if the rest of the arena is not
+// enough, it moves to a larger block and leaves the current one to the
+// entries still pointing into it. a nil arena allocates a fresh slice
+func (a *Arena) alloc(n int) []byte {
+	if a == nil {
+		return make([]byte, n)
+	}
+
+	if a.off+n > len(a.buf) {
+		size := 2 * len(a.buf)
+		if size < minArenaBytes {
+			size = minArenaBytes
+		}
+		if size < n {
+			size = n
+		}
+		a.buf = make([]byte, size)
+		a.off = 0
+	}
+
+	b := a.buf[a.off : a.off+n : a.off+n]
+	a.off += n
+	return b
+}
+
+// rest returns the unused part of the arena to be appended to, a part
+// of it is taken by calling use with the result of the appending
+func (a *Arena) rest() []byte {
+	if a == nil {
+		return nil
+	}
+	return a.buf[a.off:a.off:len(a.buf)]
+}
+
+// use takes b out of the arena if it's appended to the slice returned by
+// rest without growing out of it
+func (a *Arena) use(b []byte) {
+	if a == nil || len(b) == 0 || len(b) > len(a.buf)-a.off {
+		return
+	}
+	if &b[0] == &a.buf[a.off] {
+		a.off += len(b)
+	}
+}
@@ -0,0 +1,148 @@
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func arenaTestOptions(c Compression) *Options {
+	opts := DefaultOptions()
+	opts.Compression = c
+	opts.SyncPolicy = SyncPolicy{Mode: SyncNever}
+	return opts
+}
+
+var arenaTestPayload = bytes.Repeat([]byte("0123456789abcdef"), 16)
+
+// arenaTestEntry is testEntry(i) of CommitTs i and 256 bytes payload
+func arenaTestEntry(i int) binlogscheme.Entry {
+	ent := testEntry(i)
+	ent.CommitTs = int64(i)
+	ent.Payload = arenaTestPayload
+	return ent
+}
+
+func TestReadIntoAllocs(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionSnappy, CompressionZstd, CompressionGzip} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := arenaTestOptions(c)
+		w, _ := createTestBinlog(t, dir, opts, 6000, arenaTestEntry)
+		w.Close()
+		r, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ents := make([]binlogscheme.Entry, 100)
+		a := &Arena{}
+		// the first batch grows the arena
+		if _, err = r.ReadInto(ents, a); err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(50, func() {
+			if _, err := r.ReadInto(ents, a); err != nil {
+				t.Fatal(err)
+			}
+		})
+		r.Close()
+
+		if ents[99].CommitTs != 5199 || len(ents[0].Payload) != 256 {
+			t.Fatalf("%v: the last entry read is %d of %d bytes", c, ents[99].CommitTs, len(ents[0].Payload))
+		}
+		// gzip allocates its reader state per record
+		if c != CompressionGzip && allocs > 0 {
+			t.Errorf("%v: %v allocations per batch of 100 entries", c, allocs)
+		}
+	}
+}
+
+func TestReadIntoReader(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, arenaTestOptions(CompressionNone), 1000, arenaTestEntry)
+	r, err := b.NewReader(binlogscheme.BinlogOffset{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ents := make([]binlogscheme.Entry, 300)
+	total := 0
+	for {
+		n, err := r.ReadInto(ents, nil)
+		for i := 0; i < n; i++ {
+			if ents[i].CommitTs != int64(total) {
+				t.Fatalf("entry %d has CommitTs %d", total, ents[i].CommitTs)
+			}
+			total++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != 1000 {
+		t.Fatalf("read %d entries, want 1000", total)
+	}
+}
+
+// benchmarkRead reads the binlog of dir over and over in batches of
+// batch entries, with Read or with ReadInto and an Arena
+func benchmarkRead(b *testing.B, dir string, opts *Options, batch int, arena bool) {
+	open := func() *Binlog {
+		r, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r
+	}
+
+	r := open()
+	defer func() { r.Close() }()
+	ents := make([]binlogscheme.Entry, batch)
+	a := &Arena{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if arena {
+			_, err = r.ReadInto(ents, a)
+		} else {
+			_, err = r.Read(uint64(batch))
+		}
+		if err == io.EOF {
+			b.StopTimer()
+			r.Close()
+			r = open()
+			b.StartTimer()
+		} else if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	dir := path.Join(b.TempDir(), "binlog")
+	opts := arenaTestOptions(CompressionNone)
+	w, _ := createTestBinlog(b, dir, opts, 100000, arenaTestEntry)
+	w.Close()
+
+	b.Run("Read", func(b *testing.B) { benchmarkRead(b, dir, opts, 1, false) })
+	b.Run("ReadInto", func(b *testing.B) { benchmarkRead(b, dir, opts, 1, true) })
+}
+
+func BenchmarkReadBatch(b *testing.B) {
+	dir := path.Join(b.TempDir(), "binlog")
+	opts := arenaTestOptions(CompressionNone)
+	w, _ := createTestBinlog(b, dir, opts, 100000, arenaTestEntry)
+	w.Close()
+
+	b.Run("Read", func(b *testing.B) { benchmarkRead(b, dir, opts, 100, false) })
+	b.Run("ReadInto", func(b *testing.B) { benchmarkRead(b, dir, opts, 100, true) })
+}
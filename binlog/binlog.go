@@ -1,36 +1,85 @@
 package binlog
 
 import (
+	"errors"
 	"io"
 	"os"
-	"errors"
+	"path"
+	"sync"
 
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
 	"github.com/pingcap/tidb-binlog/util/fileutil"
 )
 
 var (
-	SegmentSizeBytes int64 = 64 * 1000 * 1000
-	ErrFileNotFound        = errors.New("file: file not found")
+	ErrFileNotFound = errors.New("file: file not found")
+	ErrClosed       = errors.New("binlog: binlog is closed")
+	// ErrPoisoned is returned by the writes after a failed fsync, the
+	// entries written before it may be lost, so the Binlog must be reopened
+	ErrPoisoned = errors.New("binlog: binlog is poisoned by a failed sync")
+	// ErrReadOnly is returned by the writes to a Binlog returned by Open
+	ErrReadOnly = errors.New("binlog: binlog is opened for reading")
 )
 
 type Binlog struct {
-	dir		string
-
-	decoder		*decoder
-	readClose	func() error
-	
-	mu		sync.Mutex
-	encoder		*encoder
-	
-	locks           []*fileutil.LockedFile
-	fp		*filePipeline
+	dir  string
+	opts *Options
+
+	// readMu guards the decoder of Read, so Read doesn't block the writes
+	readMu    sync.Mutex
+	decoder   *decoder
+	readClose func() error
+
+	mu      sync.Mutex
+	encoder *encoder
+
+	locks []*fileutil.LockedFile
+	fp    *filePipeline
+
+	// the position right after the last written entry
+	offset binlogscheme.BinlogOffset
+	// the meta of the tail file, written beside it when it's sealed
+	meta *segmentMeta
+	// the sparse index of the tail file
+	idx *indexWriter
+
+	// bytes cut off from the tail by recovery in OpenForWrite
+	discarded int64
+
+	// nil if group commit is disabled
+	gc *groupCommitter
+
+	// entries written since the last fsync
+	unsynced int
+
+	stopc chan struct{}
+	wg    sync.WaitGroup
+
+	// poisoned is set under mu when the tail file fails to sync
+	// or to be cut, no write goes after it
+	poisoned bool
+
+	// closed is set under mu, so no write goes after Close
+	closed    bool
+	closeOnce sync.Once
+	closeErr  error
+
+	// closed and reset by every write to wake up the watchers
+	notifyMu sync.Mutex
+	notify   chan struct{}
 }
 
-func Create(dirpath string) (*Binlog, error) {
+// Create creates a binlog directory for writing, opts may be nil
+func Create(dirpath string, opts *Options) (*Binlog, error) {
 	if Exist(dirpath) {
 		return nil, os.ErrExist
 	}
 
+	opts = opts.normalize()
+	if err := opts.SyncPolicy.validate(); err != nil {
+		return nil, err
+	}
+
 	// the temporary dir make the create dir atomic
 	tmpdirpath := path.Clean(dirpath) + ".tmp"
 	if fileutil.Exist(tmpdirpath) {
@@ -44,168 +93,428 @@ func Create(dirpath string) (*Binlog, error) {
 	}
 
 	p := path.Join(tmpdirpath, fileName(0))
-	f, err := fileutil.LockFile(p, os.O_WRONLY|os.O_CREATE, fileutil.PrivateFileMode)
+	f, err := fileutil.LockFile(p, os.O_WRONLY|os.O_CREATE, opts.FileMode)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := f.Seek(0, os.SEEK_END); err != nil {
+	if opts.Preallocate {
+		if err := fileutil.Preallocate(f.File, opts.SegmentSize, true); err != nil {
+			return nil, err
+		}
+	}
+
+	h, err := writeSegmentHeader(f, 0, opts)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := fileutil.Preallocate(f.File, SegmentSizeBytes, true); err != nil {
+	sealer, err := h.sealer(opts)
+	if err != nil {
 		return nil, err
 	}
 
 	binlog := &Binlog{
-		dir:      dirpath,
-		encoder:  newEncoder(f),
+		dir:     dirpath,
+		opts:    opts,
+		encoder: newEncoder(f, sealer, opts),
+		offset:  binlogscheme.BinlogOffset{Index: 0, Offset: segmentHeaderBytes},
+		meta:    &segmentMeta{Index: 0, Size: segmentHeaderBytes},
+		stopc:   make(chan struct{}),
 	}
 	binlog.locks = append(binlog.locks, f)
 	return binlog.renameFile(tmpdirpath)
 }
 
-func Open(dirpath string, offset *binlogscheme.BinlogOffset) (*Binlog, error) {
-	names, err := readBinlogNames(dirpath)
+// Open opens the binlog directory for reading from the offset, opts may be nil
+func Open(dirpath string, offset *binlogscheme.BinlogOffset, opts *Options) (*Binlog, error) {
+	opts = opts.normalize()
+
+	// keep the files from being purged while they are opened and read
+	pin := pinFiles(dirpath, offset.Index)
+	names, err := readBinlogNames(dirpath, opts.Logger)
 	if err != nil {
+		pin.release()
 		return nil, err
 	}
 
-	nameIndex, ok := searchIndex(names, offset.Index)
+	nameIndex, ok := searchIndex(names, offset.Index, opts.Logger)
 	if !ok {
+		pin.release()
 		return nil, ErrFileNotFound
 	}
 
 	first := true
+	start := *offset
 
 	rcs := make([]io.ReadCloser, 0)
-	rs  := make([io.Reader, 0])
+	rs := make([]io.Reader, 0)
 	for _, name := range names[nameIndex:] {
-		p := path.Join(dirpath, name)
-		rf, err := os.OpenFile(p, os.O_RDONLY, fileutil.PrivateFileMode)
+		index, err := parseBinlogName(name)
 		if err != nil {
 			closeAll(rcs...)
+			pin.release()
+			return nil, err
+		}
+
+		sealed := name != names[len(names)-1]
+		rf, err := openSegmentFile(dirpath, index, sealed, opts)
+		if err != nil {
+			closeAll(rcs...)
+			pin.release()
+			return nil, err
+		}
+
+		// reject the files of another format before reading any of them
+		if _, err := checkSegmentHeader(rf, index); err != nil {
+			rf.Close()
+			closeAll(rcs...)
+			pin.release()
 			return nil, err
 		}
 
 		if first {
 			first = false
 
-			index, err := parseBinlogName(name)
 			if index == offset.Index {
-				ofs, err  := rf.Seek(offset.Offset, os.SEEK_SET)
-				if err != nil {
+				tail := name == names[len(names)-1]
+				if err := checkOffset(dirpath, *offset, tail, opts); err != nil {
+					rf.Close()
 					closeAll(rcs...)
+					pin.release()
 					return nil, err
 				}
 
-				if ret <= offser.Offset {
-                                	continue
-                        	}
+				if _, err := rf.Seek(offset.Offset, os.SEEK_SET); err != nil {
+					rf.Close()
+					closeAll(rcs...)
+					pin.release()
+					return nil, err
+				}
+			} else {
+				start = binlogscheme.BinlogOffset{Index: index, Offset: 0}
 			}
 		}
 
-		rcs	= append(rcs, rf)
-		rs 	= append(rs, rf)
+		rcs = append(rcs, rf)
+		rs = append(rs, rf)
 	}
 
-	closer := func() error {return closeAll(rcs...)}
+	pin.move(start.Index)
+	closer := func() error {
+		pin.release()
+		return closeAll(rcs...)
+	}
+	// the files are closed with the Binlog, so the payloads
+	// read by ReadInto may point into their mappings
+	decoder := newDecoder(&start, opts, rs...)
+	decoder.borrow = true
 	binlog := &Binlog{
-		dir : 		dirpath,
-		start:  	offset,
-		decoder:   	newDecoder(rs...),
-		readCloser: 	closer,
+		dir:       dirpath,
+		opts:      opts,
+		decoder:   decoder,
+		readClose: closer,
 	}
 
 	return binlog, nil
 }
 
-func OpenForWrite(dirpath string) (*Binlog, error) {
-	names, err := readBinlogNames(dirpath)
-        if err != nil {
-                return  nil, err
-        }
+// OpenForWrite opens the last binlog file of the directory for appending,
+// a torn tail left by a crash is cut off first. opts may be nil
+func OpenForWrite(dirpath string, opts *Options) (*Binlog, error) {
+	opts = opts.normalize()
+	if err := opts.SyncPolicy.validate(); err != nil {
+		return nil, err
+	}
+
+	names, err := readBinlogNames(dirpath, opts.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	lastFileName := names[len(names)-1]
+	index, err := parseBinlogName(lastFileName)
+	if err != nil {
+		return nil, err
+	}
 
-	lastFileName = names[len(names)-1]
 	p := path.Join(dirpath, lastFileName)
-        f, err := fileutil.TryLockFile(p, os.O_WRONLY|os.O_CREATE, fileutil.PrivateFileMode)
-        if err != nil {
-                return nil, err
-        }
+	f, err := fileutil.TryLockFile(p, os.O_WRONLY|os.O_CREATE, opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	// the meta and index files of the sealed binlog files may be lost by a
+	// crash right after cutting, only the writer writes them back
+	restoreSidecars(dirpath, names[:len(names)-1], opts)
+
+	// the tail may be torn by a crash, cut it off before appending
+	meta, idx, discarded, err := repairTail(f, dirpath, index, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if discarded > 0 {
+		opts.Logger.Warningf("discarded %d bytes of torn data at the end of binlog file %s, data ends at offset %d", discarded, p, meta.Size)
+	}
 
-        if _, err := f.Seek(0, os.SEEK_END); err != nil {
-                return nil, err
-        }
+	if _, err := f.Seek(meta.Size, os.SEEK_SET); err != nil {
+		f.Close()
+		return nil, err
+	}
 
+	// keep appending with the key the tail file was created with
+	h, err := loadSegmentHeader(dirpath, index)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sealer, err := h.sealer(opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if sealer != nil {
+		sealer.frames = meta.Entries
+	}
+
+	iw, err := newIndexWriter(dirpath, index, idx, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	binlog := &Binlog{
+		dir:       dirpath,
+		opts:      opts,
+		encoder:   newEncoder(f, sealer, opts),
+		offset:    binlogscheme.BinlogOffset{Index: index, Offset: meta.Size},
+		meta:      meta,
+		idx:       iw,
+		discarded: discarded,
+		stopc:     make(chan struct{}),
+	}
+	binlog.locks = append(binlog.locks, f)
+	binlog.fp = newFilePipeline(binlog.dir, opts)
+	binlog.startGroupCommit()
+	binlog.startSyncLoop()
+	binlog.startGCLoop()
 
-        binlog := &Binlog{
-                dir:      dirpath,
-                encoder:  newEncoder(f),
-        }
-        binlog.locks = append(binlog.locks, f)
-	b.fp = newFilePipeline(b.dir, SegmentSizeBytes)
+	return binlog, nil
+}
 
-        return binlog, nil
+// Discarded returns the number of torn bytes that were cut off
+// from the last binlog file when it was opened for writing
+func (b *Binlog) Discarded() int64 {
+	return b.discarded
 }
 
-func (b *Binlog) Read(nums uint64) (ents []binlogscheme.Entry, err error)  {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// Read reads the entries of a Binlog returned by Open, the Binlog can
+// only have one cursor. use NewReader for the independent ones. the
+// payloads are owned by the caller, they are never borrowed from a mapping
+func (b *Binlog) Read(nums uint64) (ents []binlogscheme.Entry, err error) {
+	b.readMu.Lock()
+	defer b.readMu.Unlock()
 
-	var ent &binlogscheme.Entry{}
+	ent := &binlogscheme.Entry{}
 	decoder := b.decoder
 
-	err = decoder.decode(ent)
-	for index := 0; index < nums && err == nil; index++ {
-		
-		newEnt = binlogscheme.Entry {
-			CommitTs:	ent.CommitTs,
-			StartTs:	ent.StartTs,
-			Size:		ent.Size,
-			Payload:	ent.Payload,
-			Offset:		ent.Offset,
+	for index := uint64(0); index < nums; index++ {
+		if err = decoder.decode(ent); err != nil {
+			return
+		}
+
+		newEnt := binlogscheme.Entry{
+			CommitTs: ent.CommitTs,
+			StartTs:  ent.StartTs,
+			Size:     ent.Size,
+			Payload:  ent.Payload,
+			Offset:   ent.Offset,
 		}
 		ents = append(ents, newEnt)
-		err = decoder.decode(ent)
 	}
 
-	return 
+	return
 }
 
-func (b *Binlog) Write(ents []binlogscheme.Entry) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// ReadInto decodes at most len(ents) entries into ents and returns the
+// number of them, their payloads are allocated from the arena. it's the
+// allocation free version of Read, see Arena for how long the payloads
+// are valid. a nil arena allocates every payload. with Options.Mmap
+// the payloads of the sealed files point into their mappings instead of
+// the arena, they are valid until the Binlog is closed
+func (b *Binlog) ReadInto(ents []binlogscheme.Entry, a *Arena) (int, error) {
+	b.readMu.Lock()
+	defer b.readMu.Unlock()
+
+	a.reset()
+	for i := range ents {
+		if err := b.decoder.decodeInto(&ents[i], a); err != nil {
+			return i, err
+		}
+	}
+
+	return len(ents), nil
+}
 
+// Write appends the entries and returns after they are synced to disk.
+// if group commit is enabled, the entries of concurrent calls are
+// written together and synced by one fsync. a failed Write leaves
+// none of its entries behind, unless it fails to sync or cut the tail
+// file. whether its entries are durable is unknown then, the Binlog is
+// poisoned and fails the later writes with ErrPoisoned, it has to be
+// closed and opened again to find out. the Offset of every entry of ents is
+// filled in with where it's written, so ents must not be shared with
+// other goroutines during the call
+func (b *Binlog) Write(ents []binlogscheme.Entry) error {
 	if len(ents) == 0 {
 		return nil
 	}
 
+	if b.gc != nil {
+		return b.gc.commit(ents)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	if b.encoder == nil {
+		b.mu.Unlock()
+		return ErrReadOnly
+	}
+	err := b.commitBatchLocked([]*commitRequest{{ents: ents}})[0]
+	b.mu.Unlock()
+
+	if err == nil {
+		b.broadcast()
+	}
+	return err
+}
+
+// encode appends the frames of the entries and fills in their Offset,
+// entries failing to encode leave nothing written
+func (b *Binlog) encode(ents []binlogscheme.Entry) error {
+	sizes, err := b.encoder.encode(ents, b.offset.Offset)
+	if err != nil {
+		return err
+	}
+
 	for i := range ents {
-		if err := b.encoder.encode(&ents[i]); err != nil {
-			return err
+		// the index can be rebuilt by decoding the file,
+		// and the frames are written already, so go on
+		if err := b.idx.add(b.offset.Offset, b.meta); err != nil {
+			b.opts.Logger.Warningf("failed to write index of binlog file %s: %v", b.tail().Name(), err)
 		}
+
+		ents[i].Offset = b.offset
+		b.offset.Offset += sizes[i]
+		b.unsynced++
+		b.meta.add(&ents[i])
+		b.meta.Size = b.offset.Offset
 	}
 
-	curOff, err := b.tail().Seek(0, os.SEEK_CUR)
-	if err != nil {
+	return nil
+}
+
+// writeMark is where the tail file ends before a write,
+// a failed write is rolled back to it
+type writeMark struct {
+	offset   binlogscheme.BinlogOffset
+	meta     segmentMeta
+	unsynced int
+	idx      int
+}
+
+func (b *Binlog) mark() writeMark {
+	return writeMark{
+		offset:   b.offset,
+		meta:     *b.meta,
+		unsynced: b.unsynced,
+		idx:      len(b.idx.ib.entries),
+	}
+}
+
+// rollback cuts the tail file back to the mark after a failed write,
+// the frames written since it may be partly flushed into the file
+func (b *Binlog) rollback(m writeMark) error {
+	b.encoder.reset()
+
+	tail := b.tail()
+	if err := tail.Truncate(m.offset.Offset); err != nil {
+		return err
+	}
+	if b.opts.Preallocate {
+		if err := fileutil.Preallocate(tail.File, b.opts.SegmentSize, true); err != nil {
+			return err
+		}
+	}
+	if _, err := tail.Seek(m.offset.Offset, os.SEEK_SET); err != nil {
 		return err
 	}
 
-	if curOff < SegmentSizeBytes {
-		return b.sync()
-		return nil
+	if len(b.idx.ib.entries) != m.idx {
+		idx := b.idx.ib.entries[:m.idx]
+		if err := b.idx.close(); err != nil {
+			return err
+		}
+		iw, err := newIndexWriter(b.dir, b.seq(), idx, b.opts)
+		if err != nil {
+			return err
+		}
+		b.idx = iw
+	}
+
+	b.offset = m.offset
+	*b.meta = m.meta
+	b.unsynced = m.unsynced
+	return nil
+}
+
+// poison stops the Binlog from taking any more write after the tail
+// file fails to sync or to be cut
+func (b *Binlog) poison(err error) {
+	if !b.poisoned {
+		b.opts.Logger.Errorf("binlog %s is poisoned and takes no more write: %v", b.dir, err)
+	}
+	b.poisoned = true
+}
+
+// flushOrCut syncs the tail file according to the sync policy, or cuts
+// a new one if the tail is full or its key has sealed enough frames
+func (b *Binlog) flushOrCut() error {
+	if b.offset.Offset < b.opts.SegmentSize && !b.encoder.sealer.exhausted() {
+		return b.syncByPolicy()
 	}
 
 	return b.cut()
 }
 
+func (b *Binlog) startGroupCommit() {
+	if b.opts.GroupCommitMaxBatch <= 0 {
+		return
+	}
+
+	b.gc = newGroupCommitter(b.opts.GroupCommitMaxBatch, b.opts.GroupCommitMaxWait)
+	go b.gc.run(b)
+}
+
+// LastOffset returns the position right after the last written entry,
+// it's where the next entry will be appended
+func (b *Binlog) LastOffset() binlogscheme.BinlogOffset {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.offset
+}
+
 func (b *Binlog) cut() error {
-	off, err := b.tail().Seek(0, os.SEEK_CUR)
-	if err != nil {
-		return serr
+	if err := b.encoder.flush(); err != nil {
+		return err
 	}
 
-	if err := b.tail().Truncate(off); err != nil {
+	if err := b.tail().Truncate(b.offset.Offset); err != nil {
 		return err
 	}
 
@@ -213,13 +522,36 @@ func (b *Binlog) cut() error {
 		return err
 	}
 
-	fpath := path.Join(b.dir, BinlogName(b.seq()+1))
+	// the meta and index can be rebuilt by decoding the file,
+	// so don't fail the write
+	if err := writeSegmentMeta(b.dir, b.meta, b.opts); err != nil {
+		b.opts.Logger.Warningf("failed to write meta of binlog file %s: %v", b.tail().Name(), err)
+	}
+	if err := b.idx.seal(b.meta); err != nil {
+		b.opts.Logger.Warningf("failed to write index of binlog file %s: %v", b.tail().Name(), err)
+	}
+
+	fpath := path.Join(b.dir, fileName(b.seq()+1))
 
 	newTail, err := b.fp.Open()
 	if err != nil {
 		return err
 	}
 
+	// the header is written before the file shows up under its name,
+	// so the readers never see a binlog file without it
+	h, err := writeSegmentHeader(newTail, b.seq()+1, b.opts)
+	if err != nil {
+		return err
+	}
+	sealer, err := h.sealer(b.opts)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.Fsync(newTail.File); err != nil {
+		return err
+	}
+
 	b.locks = append(b.locks, newTail)
 
 	if err = os.Rename(newTail.Name(), fpath); err != nil {
@@ -227,14 +559,23 @@ func (b *Binlog) cut() error {
 	}
 	newTail.Close()
 
-	if newTail, err = fileutil.LockFile(fpath, os.O_WRONLY, fileutil.PrivateFileMode); err != nil {
+	if newTail, err = fileutil.LockFile(fpath, os.O_WRONLY, b.opts.FileMode); err != nil {
+		return err
+	}
+
+	if _, err = newTail.Seek(segmentHeaderBytes, os.SEEK_SET); err != nil {
 		return err
 	}
 
 	b.locks[len(b.locks)-1] = newTail
-	b.encoder = newEncoder(b.tail())
+	b.encoder = newEncoder(b.tail(), sealer, b.opts)
+	b.offset = binlogscheme.BinlogOffset{Index: b.seq(), Offset: segmentHeaderBytes}
+	b.meta = &segmentMeta{Index: b.seq(), Size: segmentHeaderBytes}
+	if b.idx, err = newIndexWriter(b.dir, b.seq(), nil, b.opts); err != nil {
+		return err
+	}
 
-	log.Infof("segmented binlog file %v is created", fpath)
+	b.opts.Logger.Infof("segmented binlog file %v is created", fpath)
 	return nil
 }
 
@@ -245,32 +586,81 @@ func (b *Binlog) sync() error {
 		}
 	}
 
-	err := fileutil.Fsync(b.tail().File)
+	if b.idx != nil {
+		if err := b.idx.flush(); err != nil {
+			return err
+		}
+	}
+
+	// the pages failing to be written back may be dropped by the kernel,
+	// so a later fsync can't tell whether the unsynced entries are durable
+	if err := fileutil.Fsync(b.tail().File); err != nil {
+		b.poison(err)
+		return err
+	}
+	b.unsynced = 0
 
-	return err
+	return nil
 }
 
+// Close stops the background work, syncs the written entries and
+// releases the files. it's safe to call more than once
 func (b *Binlog) Close() error {
+	b.closeOnce.Do(func() {
+		b.closeErr = b.close()
+	})
+	return b.closeErr
+}
+
+func (b *Binlog) close() error {
+	if b.gc != nil {
+		b.gc.stop()
+	}
+
+	if b.stopc != nil {
+		close(b.stopc)
+		b.wg.Wait()
+	}
+
+	b.readMu.Lock()
+	if b.readClose != nil {
+		if err := b.readClose(); err != nil {
+			b.readMu.Unlock()
+			return err
+		}
+		b.readClose = nil
+	}
+	b.readMu.Unlock()
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.closed = true
 
 	if b.fp != nil {
 		b.fp.Close()
 		b.fp = nil
 	}
 
-	if b.tail() != nil {
+	// a poisoned Binlog is left to be checked by the next open
+	if b.tail() != nil && !b.poisoned {
 		if err := b.sync(); err != nil {
 			return err
 		}
 	}
 
+	if b.idx != nil {
+		if err := b.idx.close(); err != nil {
+			b.opts.Logger.Errorf("failed to close index during closing binlog: %s", err)
+		}
+		b.idx = nil
+	}
+
 	for _, l := range b.locks {
 		if l == nil {
 			continue
 		}
 		if err := l.Close(); err != nil {
-			log.Errorf("failed to unlock during closing binlog: %s", err)
+			b.opts.Logger.Errorf("failed to unlock during closing binlog: %s", err)
 		}
 	}
 	return nil
@@ -285,7 +675,16 @@ func (b *Binlog) renameFile(tmpdirpath string) (*Binlog, error) {
 		return nil, err
 	}
 
-	b.fp = newFilePipeline(w.dir, SegmentSizeBytes)
+	idx, err := newIndexWriter(b.dir, 0, nil, b.opts)
+	if err != nil {
+		return nil, err
+	}
+	b.idx = idx
+
+	b.fp = newFilePipeline(b.dir, b.opts)
+	b.startGroupCommit()
+	b.startSyncLoop()
+	b.startGCLoop()
 	return b, nil
 }
 
@@ -303,13 +702,13 @@ func (b *Binlog) seq() uint64 {
 	}
 	seq, err := parseBinlogName(path.Base(t.Name()))
 	if err != nil {
-		log.Fatalf("bad binlog name %s (%v)", t.Name(), err)
+		b.opts.Logger.Fatalf("bad binlog name %s (%v)", t.Name(), err)
 	}
 	return seq
 }
 
 func closeAll(rcs ...io.ReadCloser) error {
-	for -, f := range rcs {
+	for _, f := range rcs {
 		if err := f.Close(); err != nil {
 			return err
 		}
@@ -0,0 +1,96 @@
+package binlog
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func TestAppendAfterReopen(t *testing.T) {
+	for _, preallocate := range []bool{true, false} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := repairTestOptions(preallocate)
+		offsets := writeTestBinlog(t, dir, opts, 10)
+		end := offsets[9]
+		end.Offset += testFrameBytes(9)
+
+		// the preallocated file is larger than the data, the
+		// entries are appended right after the last one anyway
+		b, err := OpenForWrite(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.LastOffset() != end {
+			t.Fatalf("preallocate %v: appending at %+v, want %+v", preallocate, b.LastOffset(), end)
+		}
+		ents := []binlogscheme.Entry{testEntry(10)}
+		if err = b.Write(ents); err != nil {
+			t.Fatal(err)
+		}
+		if ents[0].Offset != end {
+			t.Fatalf("preallocate %v: entry is written at %+v, want %+v", preallocate, ents[0].Offset, end)
+		}
+		b.Close()
+
+		fi, err := os.Stat(path.Join(dir, fileName(0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if preallocate != (fi.Size() == opts.SegmentSize) {
+			t.Fatalf("preallocate %v: binlog file of %d bytes", preallocate, fi.Size())
+		}
+
+		read, err := readAll(dir, opts)
+		if err != io.EOF || len(read) != 11 {
+			t.Fatalf("preallocate %v: read %d entries, %v", preallocate, len(read), err)
+		}
+		for i, ent := range read {
+			if ent.CommitTs != testEntry(i).CommitTs {
+				t.Fatalf("preallocate %v: entry %d has CommitTs %d", preallocate, i, ent.CommitTs)
+			}
+		}
+	}
+}
+
+func TestCutByAppendOffset(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	offsets := writeTestBinlog(t, dir, opts, 100)
+
+	// a file is cut once its data, not the preallocated file, passes the size
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i].Index == offsets[i-1].Index {
+			continue
+		}
+		if offsets[i].Offset != segmentHeaderBytes || offsets[i-1].Offset+testFrameBytes(i-1) < opts.SegmentSize {
+			t.Fatalf("entry %d at %+v follows entry %d at %+v", i, offsets[i], i-1, offsets[i-1])
+		}
+	}
+	if offsets[99].Index == 0 {
+		t.Fatal("no binlog file is cut")
+	}
+}
+
+func TestWriteReadOnly(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	writeTestBinlog(t, dir, opts, 10)
+
+	b, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// a reader has no tail file to append to
+	if err = b.Write([]binlogscheme.Entry{testEntry(10)}); err != ErrReadOnly {
+		t.Fatalf("write to a reader: %v", err)
+	}
+	if err = b.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
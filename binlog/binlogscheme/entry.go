@@ -1,68 +1,55 @@
 package binlogscheme
 
 import (
+	"encoding/binary"
 	"errors"
 )
 
 type Entry struct {
-	CommitTs	int64
-	StartTs		int64
-	Size		int64
-	Payload		[]byte
-	Offset		Offset
+	CommitTs int64
+	StartTs  int64
+	Size     int64
+	Payload  []byte
+	Offset   BinlogOffset
 }
 
-const magicByte = 0x01
+const (
+	magicByte = 0x01
+
+	// magic(1) | commitTs(8) | startTs(8) | size(8)
+	entryHeaderBytes = 25
+)
 
 var (
 	ErrorFormat = errors.New("entry format is error")
 )
 
-func (ent *Entry) Unmarshal(b []byte, offset *Binlog.BinlogOffset) error {
+func (ent *Entry) Unmarshal(b []byte, offset *BinlogOffset) error {
 	length := len(b)
 
-	if length <= 25 && b[0] != nagicByte {
+	if length < entryHeaderBytes || b[0] != magicByte {
 		return ErrorFormat
 	}
 
-	buf  : =  bytes .NewBuffer(b[17:25]) 
-	var n int64
-	err := binary.Read(buf, binary.LittleEndian, &n)
-	if err != nil {
-		return err
-	}
-
-	if n + 25 != length {
-		reurn ErrorFormat
+	n := int64(binary.LittleEndian.Uint64(b[17:25]))
+	if n+entryHeaderBytes != int64(length) {
+		return ErrorFormat
 	}
 
 	ent.Size = n
-
-	buf = bytes.NewBuffer(b[1:9])
-	err := binary.Read(buf, binary.LittleEndian, &n)
-        if err != nil {
-                return err
-        }
-	ent.CommitTs = n
-
-	buf = bytes .NewBuffer(b[9:17])
-        err := binary.Read(buf, binary.LittleEndian, &n)
-        if err != nil {
-                return err
-        }
-        ent.SatrtTs = n	
-
-	ent.Payload = b[25:]
-	ent.Offset = Binlog.BinlogOffset {
-		Index: 	offset.Index,
-		Offset:	offset.Offset,
+	ent.CommitTs = int64(binary.LittleEndian.Uint64(b[1:9]))
+	ent.StartTs = int64(binary.LittleEndian.Uint64(b[9:17]))
+	ent.Payload = b[entryHeaderBytes:]
+	ent.Offset = BinlogOffset{
+		Index:  offset.Index,
+		Offset: offset.Offset,
 	}
-	
+
 	return nil
 }
 
 func (ent *Entry) Marshal() (data []byte, err error) {
-	size := ent.Size()
+	size := ent.MarshalSize()
 	data = make([]byte, size)
 	n, err := ent.MarshalTo(data)
 	if err != nil {
@@ -72,29 +59,21 @@ func (ent *Entry) Marshal() (data []byte, err error) {
 }
 
 func (ent *Entry) MarshalTo(data []byte) (int, error) {
-	data[0] = magicByte 
-	err := binary.Write(binary.LittleEndian, ent.CommitTs)
-	if err != nil {
-		return 1, err
-	} 
-
-	err := binary.Write(data[9:], binary.LittleEndian, ent.StartTs)
-        if err != nil {
-                return 5, err
-        }
-
-	err := binary.Write(data[17:], binary.LittleEndian, ent.Size)
-        if err != nil {
-                return 5, err
-        }
-
-	for i := 0; i < len(ent.Payload); i++ {
-		data[i+25] = ent.Payload[i] 
+	size := ent.MarshalSize()
+	if len(data) < size {
+		return 0, ErrorFormat
 	}
 
-	return ent.Size(), nil
+	data[0] = magicByte
+	binary.LittleEndian.PutUint64(data[1:9], uint64(ent.CommitTs))
+	binary.LittleEndian.PutUint64(data[9:17], uint64(ent.StartTs))
+	binary.LittleEndian.PutUint64(data[17:25], uint64(len(ent.Payload)))
+	copy(data[entryHeaderBytes:], ent.Payload)
+
+	return size, nil
 }
 
-func (ent *Entry) Size() int {
-	return 25 + len(ent.Payload)
+// MarshalSize returns the length of the marshaled entry
+func (ent *Entry) MarshalSize() int {
+	return entryHeaderBytes + len(ent.Payload)
 }
@@ -1,6 +1,6 @@
 package binlogscheme
 
 type BinlogOffset struct {
-	Offset		int64
-	Index		int64
+	Offset int64
+	Index  uint64
 }
@@ -0,0 +1,38 @@
+// Package binlogtest writes binlog directories for the tests of the packages reading them
+package binlogtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// Entry returns the entry i written by Write, its CommitTs is i+1 and its StartTs is i
+func Entry(i int) binlogscheme.Entry {
+	return binlogscheme.Entry{
+		CommitTs: int64(i + 1),
+		StartTs:  int64(i),
+		Payload:  []byte(fmt.Sprintf("payload of entry %d", i)),
+	}
+}
+
+// Write creates a binlog of n entries in dir, one entry per write,
+// and returns the offset of every entry. opts may be nil
+func Write(tb testing.TB, dir string, opts *binlog.Options, n int) []binlogscheme.BinlogOffset {
+	b, err := binlog.Create(dir, opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer b.Close()
+
+	offsets := make([]binlogscheme.BinlogOffset, 0, n)
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, b.LastOffset())
+		if err = b.Write([]binlogscheme.Entry{Entry(i)}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,114 @@
+package binlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the codec of the entries, it's recorded in the frame
+// header of every entry, so the binlog files written with different
+// compressions can be read side by side
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionSnappy
+	CompressionZstd
+	CompressionGzip
+)
+
+// the codec takes two bits of the frame header
+const compressionMask = 0x3
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+
+	// a gzip reader is reset for every record instead of allocated
+	gzipReaders = sync.Pool{New: func() interface{} { return new(gzipReader) }}
+)
+
+type gzipReader struct {
+	gzip.Reader
+	src bytes.Reader
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionGzip:
+		return "gzip"
+	default:
+		return fmt.Sprintf("unknown compression %d", c)
+	}
+}
+
+// compress appends the compressed src to dst[:0]
+func compress(c Compression, dst, src []byte) ([]byte, error) {
+	switch c {
+	case CompressionSnappy:
+		return snappy.Encode(dst[:cap(dst)], src), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(src, dst[:0]), nil
+	case CompressionGzip:
+		buf := bytes.NewBuffer(dst[:0])
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(src); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("binlog: unknown compression %d", c)
+	}
+}
+
+// decompress decompresses src into the memory of the arena, a nil arena
+// allocates a fresh slice
+func decompress(c Compression, a *Arena, src []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return src, nil
+	case CompressionSnappy:
+		n, err := snappy.DecodedLen(src)
+		if err != nil {
+			return nil, err
+		}
+		return snappy.Decode(a.alloc(n), src)
+	case CompressionZstd:
+		dst, err := zstdDecoder.DecodeAll(src, a.rest())
+		if err != nil {
+			return nil, err
+		}
+		a.use(dst)
+		return dst, nil
+	case CompressionGzip:
+		r := gzipReaders.Get().(*gzipReader)
+		defer gzipReaders.Put(r)
+
+		r.src.Reset(src)
+		if err := r.Reset(&r.src); err != nil {
+			return nil, err
+		}
+		buf := bytes.NewBuffer(a.rest())
+		if _, err := buf.ReadFrom(&r.Reader); err != nil {
+			return nil, err
+		}
+		a.use(buf.Bytes())
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("binlog: unknown compression %d", c)
+	}
+}
@@ -0,0 +1,81 @@
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func TestCompression(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 8192
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	// the entries of every compression are appended to the same binlog,
+	// a small payload is kept as is since it doesn't shrink
+	large := bytes.Repeat([]byte("compressible "), 50)
+	small := []byte("x")
+	frameBytes := make(map[Compression]int64)
+	var ts int64
+	for _, c := range []Compression{CompressionNone, CompressionSnappy, CompressionZstd, CompressionGzip} {
+		o := *opts
+		o.Compression = c
+		if b, err = OpenForWrite(dir, &o); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			ents := []binlogscheme.Entry{{CommitTs: ts, Payload: large}, {CommitTs: ts + 1, Payload: small}}
+			if err = b.Write(ents); err != nil {
+				t.Fatal(err)
+			}
+			if ents[0].Offset.Index == ents[1].Offset.Index {
+				frameBytes[c] = ents[1].Offset.Offset - ents[0].Offset.Offset
+			}
+			ts += 2
+		}
+		b.Close()
+	}
+
+	for _, c := range []Compression{CompressionSnappy, CompressionZstd, CompressionGzip} {
+		if frameBytes[c] >= frameBytes[CompressionNone] {
+			t.Errorf("%v: the frame takes %d bytes, %d uncompressed", c, frameBytes[c], frameBytes[CompressionNone])
+		}
+	}
+
+	// the codec is read from the frames whatever the options are
+	ents, err := readAll(dir, DefaultOptions())
+	if err != io.EOF || int64(len(ents)) != ts {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+	for i, ent := range ents {
+		want := large
+		if i%2 == 1 {
+			want = small
+		}
+		if ent.CommitTs != int64(i) || !bytes.Equal(ent.Payload, want) {
+			t.Fatalf("entry %d is %d of %d bytes", i, ent.CommitTs, len(ent.Payload))
+		}
+	}
+}
+
+func TestCompressionString(t *testing.T) {
+	for c, want := range map[Compression]string{
+		CompressionNone:   "none",
+		CompressionSnappy: "snappy",
+		CompressionZstd:   "zstd",
+		CompressionGzip:   "gzip",
+		Compression(7):    "unknown compression 7",
+	} {
+		if c.String() != want {
+			t.Fatalf("compression %q, want %q", c.String(), want)
+		}
+	}
+}
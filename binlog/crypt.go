@@ -0,0 +1,279 @@
+package binlog
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// an encrypted record is laid out as
+// | key id(4) | nonce(12) | sealed record | tag(16) |
+// the additional data of the AES-GCM seal is the key id with the index
+// of the binlog file and the offset of the frame, so a frame can't be
+// moved to another place without failing to open
+const (
+	keyIDBytes = 4
+	nonceBytes = 12
+	aadBytes   = keyIDBytes + 8 + 8
+)
+
+// maxSealedFrames is how many frames are sealed with the key of one binlog
+// file before a new file is cut. the nonces are random, which keeps a key
+// safe for 2^32 seals, so it leaves plenty of room for the last write
+var maxSealedFrames int64 = 1 << 30
+
+var (
+	ErrNoKeyProvider = errors.New("binlog: the entry is encrypted but no key provider is set")
+	ErrKeyNotFound   = errors.New("binlog: encryption key not found")
+)
+
+// KeyProvider provides the AES keys of the encrypted binlog files. a new
+// binlog file is encrypted with the current key, and the id of the key is
+// recorded in its header and frames, so the keys can be rotated as long
+// as the old ones can still be found by their ids
+type KeyProvider interface {
+	// CurrentKey returns the key that the new binlog files are encrypted with
+	CurrentKey() (id uint32, key []byte, err error)
+	// Key returns the key of the id, or ErrKeyNotFound
+	Key(id uint32) ([]byte, error)
+}
+
+// FileKeyProvider reads the keys from a file of lines like
+// <key id> <hex encoded 16, 24 or 32 bytes AES key>
+// the key with the largest id is the current one. the file is reread
+// whenever a new binlog file is created or an unknown id is asked, so a
+// key is rotated by appending a line with a larger id
+type FileKeyProvider struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[uint32][]byte
+	cur  uint32
+}
+
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{path: path}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileKeyProvider) CurrentKey() (uint32, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return 0, nil, err
+	}
+	return p.cur, p.keys[p.cur], nil
+}
+
+func (p *FileKeyProvider) Key(id uint32) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (p *FileKeyProvider) load() error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	keys := make(map[uint32][]byte)
+	var cur uint32
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("binlog: bad key file %s line %d", p.path, n)
+		}
+
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("binlog: bad key id of key file %s line %d: %v", p.path, n, err)
+		}
+
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return fmt.Errorf("binlog: bad key of key file %s line %d: %v", p.path, n, err)
+		}
+		if _, err = aes.NewCipher(key); err != nil {
+			return fmt.Errorf("binlog: bad key of key file %s line %d: %v", p.path, n, err)
+		}
+
+		if len(keys) == 0 || uint32(id) > cur {
+			cur = uint32(id)
+		}
+		keys[uint32(id)] = key
+	}
+	if err = s.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("binlog: no key in key file %s", p.path)
+	}
+
+	p.keys, p.cur = keys, cur
+	return nil
+}
+
+// segmentKey derives the key of one binlog file from the key of the
+// provider, every binlog file has its own key so that the random nonces
+// of a key are bounded by the frames of one file
+func segmentKey(key []byte, h *segmentHeader) []byte {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], h.Index)
+	binary.LittleEndian.PutUint64(b[8:], uint64(h.CreateTime.UnixNano()))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("tidb-binlog segment key"))
+	mac.Write(b[:])
+	return mac.Sum(nil)[:len(key)]
+}
+
+func recordAAD(aad *[aadBytes]byte, id uint32, index uint64, offset int64) []byte {
+	binary.LittleEndian.PutUint32(aad[:keyIDBytes], id)
+	binary.LittleEndian.PutUint64(aad[keyIDBytes:keyIDBytes+8], index)
+	binary.LittleEndian.PutUint64(aad[keyIDBytes+8:], uint64(offset))
+	return aad[:]
+}
+
+// sealer encrypts the records of one binlog file with the key of the file
+type sealer struct {
+	keyID uint32
+	index uint64
+	aead  cipher.AEAD
+	// frames is how many frames are sealed with the key
+	frames int64
+}
+
+func newSealer(id uint32, key []byte, h *segmentHeader) (*sealer, error) {
+	aead, err := newAEAD(segmentKey(key, h))
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{keyID: id, index: h.Index, aead: aead}, nil
+}
+
+// seal appends the encrypted src of the frame at offset to dst[:0]
+func (s *sealer) seal(dst, src []byte, offset int64) ([]byte, error) {
+	n := keyIDBytes + nonceBytes
+	if cap(dst) < n+len(src)+s.aead.Overhead() {
+		dst = make([]byte, n, n+len(src)+s.aead.Overhead())
+	}
+	dst = dst[:n]
+
+	binary.LittleEndian.PutUint32(dst[:keyIDBytes], s.keyID)
+	if _, err := io.ReadFull(rand.Reader, dst[keyIDBytes:n]); err != nil {
+		return nil, err
+	}
+
+	s.frames++
+	var aad [aadBytes]byte
+	return s.aead.Seal(dst, dst[keyIDBytes:n], src, recordAAD(&aad, s.keyID, s.index, offset)), nil
+}
+
+// exhausted returns whether the key has sealed enough frames
+// and the binlog file should be cut to rotate it
+func (s *sealer) exhausted() bool {
+	return s != nil && s.frames >= maxSealedFrames
+}
+
+// opener decrypts the records with the keys of the provider,
+// the cipher of the binlog file being read is cached
+type opener struct {
+	keys KeyProvider
+
+	keyID uint32
+	index uint64
+	aead  cipher.AEAD
+}
+
+func newOpener(keys KeyProvider) *opener {
+	return &opener{keys: keys}
+}
+
+// open decrypts the record of the frame at offset in place,
+// the key of the file is derived from the header of f
+func (o *opener) open(src []byte, offset binlogscheme.BinlogOffset, f interface{}) ([]byte, error) {
+	if o.keys == nil {
+		return nil, ErrNoKeyProvider
+	}
+
+	n := keyIDBytes + nonceBytes
+	if len(src) < n {
+		return nil, errors.New("binlog: encrypted record is too short")
+	}
+
+	id := binary.LittleEndian.Uint32(src[:keyIDBytes])
+	if o.aead == nil || o.keyID != id || o.index != offset.Index {
+		aead, err := o.fileAEAD(id, offset.Index, f)
+		if err != nil {
+			return nil, err
+		}
+		o.keyID, o.index, o.aead = id, offset.Index, aead
+	}
+
+	// the record is opened in place, so it takes no more memory
+	var aad [aadBytes]byte
+	return o.aead.Open(src[n:n], src[keyIDBytes:n], src[n:], recordAAD(&aad, id, offset.Index, offset.Offset))
+}
+
+func (o *opener) fileAEAD(id uint32, index uint64, f interface{}) (cipher.AEAD, error) {
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("binlog: can't read the header of binlog file %s", fileName(index))
+	}
+	h, err := checkSegmentHeader(ra, index)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := o.keys.Key(id)
+	if err != nil {
+		return nil, fmt.Errorf("binlog: failed to get key %d: %v", id, err)
+	}
+	return newAEAD(segmentKey(key, h))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,208 @@
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+const (
+	testKey1 = "1 000102030405060708090a0b0c0d0e0f\n"
+	testKey2 = "2 0f0e0d0c0b0a09080706050403020100\n"
+)
+
+func testKeyProvider(t *testing.T, keyFile, keys string) *FileKeyProvider {
+	if err := ioutil.WriteFile(keyFile, []byte(keys), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kp, err := NewFileKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kp
+}
+
+func encryptedTestOptions(kp KeyProvider) *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 4096
+	opts.KeyProvider = kp
+	return opts
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	dir := path.Join(tmp, "binlog")
+	keyFile := path.Join(tmp, "keys")
+	opts := encryptedTestOptions(testKeyProvider(t, keyFile, testKey1))
+	opts.Compression = CompressionSnappy
+
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("secret-pii-secret-pii")
+	for i := 0; i < 100; i++ {
+		// the new binlog files are encrypted with the rotated key
+		if i == 50 {
+			if err = ioutil.WriteFile(keyFile, []byte(testKey1+testKey2), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = b.Write([]binlogscheme.Entry{{CommitTs: int64(i), Payload: secret}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := readBinlogNames(dir, opts.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[uint32]bool)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(path.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("secret-pii")) {
+			t.Fatalf("binlog file %s has the plaintext", name)
+		}
+		index, _ := parseBinlogName(name)
+		h, err := unmarshalSegmentHeader(data[:segmentHeaderBytes], index)
+		if err != nil || !h.Encrypted {
+			t.Fatalf("header of %s is %+v, %v", name, h, err)
+		}
+		ids[h.KeyID] = true
+	}
+	if !ids[1] || !ids[2] {
+		t.Fatalf("binlog files are encrypted with keys %v, want 1 and 2", ids)
+	}
+
+	ents, err := readAll(dir, opts)
+	if err != io.EOF || len(ents) != 100 {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+	for i, ent := range ents {
+		if ent.CommitTs != int64(i) || !bytes.Equal(ent.Payload, secret) {
+			t.Fatalf("entry %d is %+v", i, ent)
+		}
+	}
+
+	// appending to the tail keeps its key
+	w, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]binlogscheme.Entry{{CommitTs: 100, Payload: secret}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	off, err := searchCommitTs(dir, 60, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(dir, &off, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if ents, err = r.Read(1000); len(ents) != 41 || ents[0].CommitTs != 60 {
+		t.Fatalf("read %d entries from commit ts 60, %v", len(ents), err)
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	tmp := t.TempDir()
+	dir := path.Join(tmp, "binlog")
+	opts := encryptedTestOptions(testKeyProvider(t, path.Join(tmp, "keys"), testKey1))
+	writeTestBinlog(t, dir, opts, 10)
+
+	if _, err := readAll(dir, DefaultOptions()); err != ErrNoKeyProvider {
+		t.Fatalf("read without a key provider: %v", err)
+	}
+
+	// the key of the same id but different bytes
+	wrong := encryptedTestOptions(testKeyProvider(t, path.Join(tmp, "wrong"), "1 0f0e0d0c0b0a09080706050403020100\n"))
+	ents, err := readAll(dir, wrong)
+	if len(ents) != 0 || err == nil || err == io.EOF || IsCorruption(err) {
+		t.Fatalf("read %d entries with a wrong key, %v", len(ents), err)
+	}
+
+	missing := encryptedTestOptions(testKeyProvider(t, path.Join(tmp, "missing"), testKey2))
+	if ents, err = readAll(dir, missing); len(ents) != 0 || err == nil || err == io.EOF {
+		t.Fatalf("read %d entries without the key, %v", len(ents), err)
+	}
+}
+
+func TestEncryptionMovedFrame(t *testing.T) {
+	tmp := t.TempDir()
+	dir := path.Join(tmp, "binlog")
+	opts := encryptedTestOptions(testKeyProvider(t, path.Join(tmp, "keys"), testKey1))
+	opts.SegmentSize = 1 << 20
+	offsets := writeTestBinlog(t, dir, opts, 10)
+
+	f, err := os.Open(path.Join(dir, fileName(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, offsets[3].Offset-offsets[2].Offset)
+	_, err = f.ReadAt(frame, offsets[2].Offset)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(frame)) != offsets[4].Offset-offsets[3].Offset {
+		t.Fatal("the frames of the test entries differ in size")
+	}
+
+	// the crc still matches, but the frame is sealed for another offset
+	writeAt(t, dir, 0, offsets[3].Offset, frame)
+	ents, err := readAll(dir, opts)
+	if len(ents) != 3 || err == nil || err == io.EOF {
+		t.Fatalf("read %d entries over a moved frame, %v", len(ents), err)
+	}
+}
+
+func TestEncryptionRotateByFrames(t *testing.T) {
+	defer func(n int64) { maxSealedFrames = n }(maxSealedFrames)
+	maxSealedFrames = 5
+
+	tmp := t.TempDir()
+	dir := path.Join(tmp, "binlog")
+	opts := encryptedTestOptions(testKeyProvider(t, path.Join(tmp, "keys"), testKey1))
+	opts.SegmentSize = 1 << 20
+	writeTestBinlog(t, dir, opts, 12)
+
+	names, err := readBinlogNames(dir, opts.Logger)
+	if err != nil || len(names) != 3 {
+		t.Fatalf("binlog files are %v, %v, want 3 of them", names, err)
+	}
+
+	// the frames already in the tail are counted after reopening
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 12; i < 16; i++ {
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Close()
+
+	if names, err = readBinlogNames(dir, opts.Logger); err != nil || len(names) != 4 {
+		t.Fatalf("binlog files are %v, %v, want 4 of them", names, err)
+	}
+	ents, err := readAll(dir, opts)
+	if err != io.EOF || len(ents) != 16 {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+}
@@ -2,75 +2,270 @@ package binlog
 
 import (
 	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"math"
+	"os"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
 )
 
-type decoder struct {
-	brs	[]*bufio.Reader
-	offset *Binlog.BinlogOffset
+// maxRecordBytes is the largest record of a frame, a larger lenField
+// read from a binlog file can only be corrupted. it's a var for the tests
+var maxRecordBytes int64 = 1 << 30
+
+// CorruptionError is returned when a frame fails its crc check or its
+// lenField is out of bounds, Offset is the position of the bad frame
+type CorruptionError struct {
+	Offset   binlogscheme.BinlogOffset
+	Expected uint32
+	Actual   uint32
+	// Length is the lenField of the frame if it's out of bounds
+	// instead of failing the crc check, a zero one is followed by data
+	Length int64
+}
+
+func (e *CorruptionError) Error() string {
+	if e.Expected == e.Actual {
+		return fmt.Sprintf("binlog: corrupted entry at index %d offset %d, bad length field %016x",
+			e.Offset.Index, e.Offset.Offset, uint64(e.Length))
+	}
+	return fmt.Sprintf("binlog: corrupted entry at index %d offset %d, crc expected %08x, got %08x",
+		e.Offset.Index, e.Offset.Offset, e.Expected, e.Actual)
+}
+
+// IsCorruption returns whether the err is caused by a corrupted frame
+func IsCorruption(err error) bool {
+	var e *CorruptionError
+	return errors.As(err, &e)
+}
+
+// fileBound is the size of a binlog file being decoded, it's refreshed
+// when a frame looks past it since the tail file keeps growing
+type fileBound struct {
+	f    interface{}
+	size int64
 }
 
-func newDecoder(offset *Binlog.BinlogOffset, r ...io.Reader) {
-	readers := make([]*bufio.Reader, len(r))
-	for i := range  r {
-		readers[i] = bufio.NewReader(r[i])
+// fits returns whether the file holds the bytes up to end,
+// it's true if the size of the file is unknown
+func (b *fileBound) fits(end int64) bool {
+	if end <= b.size {
+		return true
 	}
 
+	size, ok := fileSize(b.f)
+	if !ok {
+		return true
+	}
+	b.size = size
+	return end <= b.size
+}
 
-	return &decoder {
-		brs:	readers,
-		offset:	offset,
+// fileSize returns the current size of a binlog file opened for reading
+func fileSize(f interface{}) (int64, bool) {
+	switch f := f.(type) {
+	case *os.File:
+		info, err := f.Stat()
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+	case *mmapFile:
+		return int64(len(f.data)), true
+	}
+	return 0, false
+}
+
+type decoder struct {
+	brs []io.Reader
+	// bounds are the sizes of the files under brs
+	bounds []fileBound
+	offset *binlogscheme.BinlogOffset
+	opener *opener
+	// borrow makes the payloads decoded into an arena from a mapped file
+	// point into the mapping, it's set if the file outlives the arena use
+	borrow bool
+
+	lenBuf [8]byte
+}
+
+func newDecoder(offset *binlogscheme.BinlogOffset, opts *Options, r ...io.Reader) *decoder {
+	readers := make([]io.Reader, len(r))
+	bounds := make([]fileBound, len(r))
+	for i := range r {
+		bounds[i].f = r[i]
+		// a mapped file is read without another copy through the buffer
+		if m, ok := r[i].(*mmapFile); ok {
+			readers[i] = m
+			continue
+		}
+		readers[i] = bufio.NewReaderSize(r[i], opts.ReadBufferSize)
+	}
+
+	return &decoder{
+		brs:    readers,
+		bounds: bounds,
+		offset: offset,
+		opener: newOpener(opts.KeyProvider),
 	}
 }
 
 func (d *decoder) decode(ent *binlogscheme.Entry) error {
+	return d.decodeInto(ent, nil)
+}
+
+// decodeInto decodes the next entry with its payload allocated from
+// the arena, a nil arena allocates the payload alone
+func (d *decoder) decodeInto(ent *binlogscheme.Entry, a *Arena) error {
 	if len(d.brs) == 0 {
 		return io.EOF
 	}
 
-	l, err := readInt64(d.brs[0])
+	// a binlog file is read from its beginning, skip over its header
+	if d.offset.Offset == 0 {
+		if _, err := readSegmentHeader(d.brs[0], d.offset.Index); err != nil {
+			return err
+		}
+		d.offset.Offset = segmentHeaderBytes
+	}
+
+	l, err := d.readInt64(d.brs[0])
 	if err == io.EOF || (err == nil && l == 0) {
 		d.brs = d.brs[1:]
+		d.bounds = d.bounds[1:]
 		if len(d.brs) == 0 {
 			return io.EOF
 		}
 		d.offset.Index += 1
 		d.offset.Offset = 0
-		return d.decode(ent)
+		return d.decodeInto(ent, a)
 	}
 	if err != nil {
 		return err
 	}
 
-	entBytes, padBytes := decodeFrameSize(l)
-
-	data := make([]byte, recBytes+padBytes)
-	if _, err = io.ReadFull(d.brs[0], data); err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
-		}
+	n, err := d.decodeRecord(d.brs[0], &d.bounds[0], l, ent, a)
+	if err != nil {
 		return err
 	}
 
-	if err := ent.Unmarshal(data[:entBytes], d.offset); err != nil {
+	d.offset.Offset += n
+
+	return nil
+}
+
+// decodeAt decodes the frame at offset of the binlog file r into the arena,
+// a zero lenField means that there is no frame and returns io.EOF
+func (d *decoder) decodeAt(r io.ReaderAt, offset binlogscheme.BinlogOffset, ent *binlogscheme.Entry, a *Arena) error {
+	*d.offset = offset
+
+	var sr io.Reader
+	if m, ok := r.(*mmapFile); ok {
+		// a view of the mapping is sliced instead of copied from
+		sr = &mmapFile{data: m.data, off: offset.Offset}
+	} else {
+		sr = io.NewSectionReader(r, offset.Offset, math.MaxInt64-offset.Offset)
+	}
+	l, err := d.readInt64(sr)
+	if err == nil && l == 0 {
+		err = io.EOF
+	}
+	if err != nil {
 		return err
 	}
 
-	d.offset.Offset += entBytes +  padBytes + 8
+	_, err = d.decodeRecord(sr, &fileBound{f: r}, l, ent, a)
+	return err
+}
 
-	return nil
+// decodeRecord reads the rest of the frame of lenField l from r into ent
+// and returns the bytes of the whole frame. the frame must fit in the file
+// of bound b, so that a corrupted lenField doesn't allocate a huge record
+func (d *decoder) decodeRecord(r io.Reader, b *fileBound, l int64, ent *binlogscheme.Entry, a *Arena) (int64, error) {
+	entBytes, padBytes, codec, encrypted := decodeFrameSize(l)
+	if !saneFrameSize(entBytes, padBytes) ||
+		!b.fits(d.offset.Offset+frameHeaderBytes+entBytes+padBytes) {
+		return 0, &CorruptionError{
+			Offset: *d.offset,
+			Length: l,
+		}
+	}
+
+	data, err := d.readFrame(r, int(crcBytes+entBytes+padBytes), encrypted, a)
+	if err != nil {
+		return 0, err
+	}
+
+	expected := binary.LittleEndian.Uint32(data[:crcBytes])
+	record := data[crcBytes : crcBytes+entBytes]
+	if actual := crc32.Checksum(record, crcTable); actual != expected {
+		return 0, &CorruptionError{
+			Offset:   *d.offset,
+			Expected: expected,
+			Actual:   actual,
+		}
+	}
+
+	if encrypted {
+		if record, err = d.opener.open(record, *d.offset, b.f); err != nil {
+			return 0, err
+		}
+	}
+
+	if record, err = decompress(codec, a, record); err != nil {
+		return 0, err
+	}
+
+	if err = ent.Unmarshal(record, d.offset); err != nil {
+		return 0, err
+	}
+
+	return frameHeaderBytes + entBytes + padBytes, nil
 }
 
-func decodeFrameSize(lenField int64) (recBytes int64, padBytes int64) {
+// readFrame reads the n bytes of the frame behind its lenField. they are
+// sliced from the mapping if borrowed into an arena, except an encrypted
+// record, which is opened in place while the mapping is read-only. the
+// payloads without an arena are the caller's, so they are always copied
+func (d *decoder) readFrame(r io.Reader, n int, encrypted bool, a *Arena) ([]byte, error) {
+	if m, ok := r.(*mmapFile); ok && d.borrow && a != nil && !encrypted {
+		return m.next(n)
+	}
+
+	data := a.alloc(n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func decodeFrameSize(lenField int64) (recBytes int64, padBytes int64, codec Compression, encrypted bool) {
 	recBytes = int64(uint64(lenField) & ^(uint64(0xff) << 56))
 	if lenField < 0 {
-		padBytes = int64((uint64(lenField) >> 56) & 0x7)
+		flags := uint64(lenField) >> 56
+		padBytes = int64(flags & 0x7)
+		codec = Compression((flags >> 3) & compressionMask)
+		encrypted = flags&frameEncrypted != 0
 	}
 	return
 }
 
-func readInt64(r io.Reader) (int64, error) {
-	var n int64
-	err := binary.Read(r, binary.LittleEndian, &n)
-	return n, err
+// saneFrameSize returns whether the sizes decoded from a lenField can be
+// written by the encoder, the padding must align the frame to 8 bytes
+func saneFrameSize(recBytes, padBytes int64) bool {
+	return recBytes <= maxRecordBytes && padBytes == (8-(recBytes+crcBytes)%8)%8
+}
+
+func (d *decoder) readInt64(r io.Reader) (int64, error) {
+	if _, err := io.ReadFull(r, d.lenBuf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(d.lenBuf[:])), nil
 }
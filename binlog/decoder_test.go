@@ -0,0 +1,167 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// writeTestBinlog creates a binlog of n entries in dir, one entry per
+// write, and returns the offset of every entry
+func writeTestBinlog(tb testing.TB, dir string, opts *Options, n int) []binlogscheme.BinlogOffset {
+	b, offsets := createTestBinlog(tb, dir, opts, n, testEntry)
+	if err := b.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return offsets
+}
+
+// createTestBinlog is writeTestBinlog of the entries made by ent, which
+// keeps the binlog open for writing until the end of the test
+func createTestBinlog(tb testing.TB, dir string, opts *Options, n int, ent func(int) binlogscheme.Entry) (*Binlog, []binlogscheme.BinlogOffset) {
+	b, err := Create(dir, opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { b.Close() })
+
+	offsets := make([]binlogscheme.BinlogOffset, 0, n)
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, b.LastOffset())
+		if err = b.Write([]binlogscheme.Entry{ent(i)}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return b, offsets
+}
+
+func testEntry(i int) binlogscheme.Entry {
+	return binlogscheme.Entry{
+		CommitTs: int64(i + 1),
+		StartTs:  int64(i),
+		Payload:  []byte(fmt.Sprintf("payload of entry %d", i)),
+	}
+}
+
+// readAll reads the binlog of dir from its beginning till an error
+func readAll(dir string, opts *Options) ([]binlogscheme.Entry, error) {
+	b, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	return b.Read(1 << 20)
+}
+
+// writeAt overwrites the binlog file of index at offset
+func writeAt(t *testing.T, dir string, index uint64, offset int64, data []byte) {
+	f, err := os.OpenFile(path.Join(dir, fileName(index)), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = f.WriteAt(data, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flipPayload overwrites the last payload byte of testEntry(i) written at offset
+func flipPayload(t *testing.T, dir string, offset binlogscheme.BinlogOffset, i int) {
+	ent := testEntry(i)
+	writeAt(t, dir, offset.Index, offset.Offset+frameHeaderBytes+int64(ent.MarshalSize())-1, []byte{'!'})
+}
+
+// testFrameBytes returns the bytes of the frame of testEntry(i) not compressed
+func testFrameBytes(i int) int64 {
+	ent := testEntry(i)
+	_, padBytes := encodeFrameSize(ent.MarshalSize(), CompressionNone, false)
+	return frameHeaderBytes + int64(ent.MarshalSize()+padBytes)
+}
+
+func TestDecodeAll(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	offsets := writeTestBinlog(t, dir, opts, 100)
+
+	ents, err := readAll(dir, opts)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if len(ents) != len(offsets) {
+		t.Fatalf("read %d entries, want %d", len(ents), len(offsets))
+	}
+	for i, ent := range ents {
+		want := testEntry(i)
+		if ent.CommitTs != want.CommitTs || ent.StartTs != want.StartTs || string(ent.Payload) != string(want.Payload) {
+			t.Fatalf("entry %d is %+v, want %+v", i, ent, want)
+		}
+		if ent.Offset != offsets[i] {
+			t.Fatalf("entry %d is at %+v, want %+v", i, ent.Offset, offsets[i])
+		}
+	}
+}
+
+func TestDecodeFlippedPayload(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	offsets := writeTestBinlog(t, dir, opts, 10)
+
+	bad := offsets[5]
+	flipPayload(t, dir, bad, 5)
+
+	ents, err := readAll(dir, opts)
+	if len(ents) != 5 {
+		t.Fatalf("read %d entries before the corrupted one, want 5", len(ents))
+	}
+	if !IsCorruption(err) {
+		t.Fatalf("read a flipped payload: %v", err)
+	}
+	if ce := err.(*CorruptionError); ce.Offset != bad || ce.Expected == ce.Actual || ce.Length != 0 {
+		t.Fatalf("corruption is %+v, want a crc mismatch at %+v", ce, bad)
+	}
+}
+
+func TestDecodeBadLength(t *testing.T) {
+	for name, l := range map[string]uint64{
+		"too large":    uint64(0x80)<<56 | 1<<40,
+		"past the end": 1 << 20,
+		"bad padding":  uint64(0x87)<<56 | 16,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "binlog")
+			opts := DefaultOptions()
+			offsets := writeTestBinlog(t, dir, opts, 10)
+
+			bad := offsets[3]
+			lenField := make([]byte, 8)
+			binary.LittleEndian.PutUint64(lenField, l)
+			writeAt(t, dir, bad.Index, bad.Offset, lenField)
+
+			ents, err := readAll(dir, opts)
+			if len(ents) != 3 || !IsCorruption(err) {
+				t.Fatalf("read %d entries, %v", len(ents), err)
+			}
+			if ce := err.(*CorruptionError); ce.Offset != bad || ce.Length != int64(l) {
+				t.Fatalf("corruption is %+v, want a bad length at %+v", ce, bad)
+			}
+		})
+	}
+}
+
+func TestIsCorruptionWrapped(t *testing.T) {
+	err := fmt.Errorf("read binlog: %w", &CorruptionError{})
+	if !IsCorruption(err) {
+		t.Fatal("a wrapped corruption is not detected")
+	}
+	if IsCorruption(io.ErrUnexpectedEOF) {
+		t.Fatal("a short read is a corruption")
+	}
+}
@@ -1,35 +1,103 @@
 package binlog
 
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
 
-const binlogPageBytes  = 4096
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+const (
+	binlogPageBytes = 4096
+
+	// every frame is laid out as
+	// | lenField(8) | crc(4) | record | padding |
+	// the padding aligns the whole frame to 8 bytes. the low 56 bits of
+	// lenField is the record bytes, if the top bit is set, the bits 0-2 of
+	// the top byte are the padding bytes, the bits 3-4 are the compression
+	// and the bit 5 tells whether the record is encrypted. a record is
+	// compressed before it's encrypted, the crc covers it as it's stored
+	frameHeaderBytes = 8 + crcBytes
+	crcBytes         = 4
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrEntryTooLarge is returned when an entry is stored larger than maxRecordBytes
+var ErrEntryTooLarge = errors.New("binlog: entry is too large")
 
 type encoder struct {
 	mu sync.Mutex
-	bw io.Write
+	w  io.Writer
+	bw *bufio.Writer
+	// failed is set when a write to bw fails, the frames buffered
+	// before it may be partly in the file
+	failed bool
+
+	compression Compression
+	// sealer is nil if the binlog file is not encrypted
+	sealer *sealer
 
-	buf       []byte
-	uint64buf []byte
+	buf  []byte
+	cbuf []byte
+	ebuf []byte
+	// frames are the frames of one encode staged before written
+	frames []byte
 }
 
-func newEncoder(w io.Writer) *encoder {
+func newEncoder(w io.Writer, s *sealer, opts *Options) *encoder {
 	return &encoder{
-		bw:        w
-		buf:       make([]byte, 1024*1024),
-		uint64buf: make([]byte, 8),
+		w:           w,
+		bw:          bufio.NewWriterSize(w, opts.WriteBufferSize),
+		compression: opts.Compression,
+		sealer:      s,
+		buf:         make([]byte, opts.EncoderBufferSize),
 	}
 }
 
-func (e *encoder) encode(ent *binlogscheme.Entry) error {
+// encode writes the entries as frames from offset of the binlog file
+// and returns the bytes of every frame. all the frames are staged before written, so an entry failing
+// to encode leaves nothing written. a failed write sets failed
+func (e *encoder) encode(ents []binlogscheme.Entry, offset int64) ([]int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	e.frames = e.frames[:0]
+	sizes := make([]int64, len(ents))
+	for i := range ents {
+		n := len(e.frames)
+		if err := e.stage(&ents[i], offset+int64(n)); err != nil {
+			return nil, err
+		}
+		sizes[i] = int64(len(e.frames) - n)
+	}
+
+	if _, err := e.bw.Write(e.frames); err != nil {
+		e.failed = true
+		return nil, err
+	}
+
+	// don't hold the staging buffer of a huge entry
+	if cap(e.frames) > 4*len(e.buf) {
+		e.frames = nil
+	}
+
+	return sizes, nil
+}
+
+// stage appends the frame of the entry at offset to frames
+func (e *encoder) stage(ent *binlogscheme.Entry, offset int64) error {
 	var (
 		data []byte
 		err  error
 		n    int
 	)
 
-	if ent.Size() > len(e.buf) {
+	if ent.MarshalSize() > len(e.buf) {
 		data, err = ent.Marshal()
 		if err != nil {
 			return err
@@ -42,23 +110,63 @@ func (e *encoder) encode(ent *binlogscheme.Entry) error {
 		data = e.buf[:n]
 	}
 
-	lenField, padBytes := encodeFrameSize(len(data))
-	if err = writeUint64(e.bw, lenField, e.uint64buf); err != nil {
-		return err
+	codec := CompressionNone
+	if e.compression != CompressionNone {
+		compressed, err := compress(e.compression, e.cbuf, data)
+		if err != nil {
+			return err
+		}
+		e.cbuf = compressed
+
+		// keep the record as it is if it doesn't shrink
+		if len(compressed) < len(data) {
+			data, codec = compressed, e.compression
+		}
+	}
+
+	if e.sealer != nil {
+		if e.ebuf, err = e.sealer.seal(e.ebuf, data, offset); err != nil {
+			return err
+		}
+		data = e.ebuf
 	}
 
-	if padBytes != 0 {
-		data = append(data, make([]byte, padBytes)...)
+	if int64(len(data)) > maxRecordBytes {
+		return ErrEntryTooLarge
 	}
-	_, err = e.bw.Write(data)
-	return err
+
+	crc := crc32.Checksum(data, crcTable)
+	lenField, padBytes := encodeFrameSize(len(data), codec, e.sealer != nil)
+	e.frames = binary.LittleEndian.AppendUint64(e.frames, lenField)
+	e.frames = binary.LittleEndian.AppendUint32(e.frames, crc)
+	e.frames = append(e.frames, data...)
+	for i := 0; i < padBytes; i++ {
+		e.frames = append(e.frames, 0)
+	}
+
+	return nil
 }
 
-func encodeFrameSize(dataBytes int) (lenField uint64, padBytes int) {
+// reset drops the buffered frames after a failed write
+func (e *encoder) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.bw.Reset(e.w)
+	e.failed = false
+}
+
+const frameEncrypted = 1 << 5
+
+func encodeFrameSize(dataBytes int, codec Compression, encrypted bool) (lenField uint64, padBytes int) {
 	lenField = uint64(dataBytes)
-	padBytes = (8 - (dataBytes % 8)) % 8
-	if padBytes != 0 {
-		lenField |= uint64(0x80|padBytes) << 56
+	padBytes = (8 - ((dataBytes + crcBytes) % 8)) % 8
+	if padBytes != 0 || codec != CompressionNone || encrypted {
+		flags := 0x80 | int(codec)<<3 | padBytes
+		if encrypted {
+			flags |= frameEncrypted
+		}
+		lenField |= uint64(flags) << 56
 	}
 	return
 }
@@ -68,9 +176,3 @@ func (e *encoder) flush() error {
 	defer e.mu.Unlock()
 	return e.bw.Flush()
 }
-
-func writeUint64(w io.Writer, n uint64, buf []byte) error {
-	binary.LittleEndian.PutUint64(buf, n)
-	_, err := w.Write(buf)
-	return err
-}
@@ -1,32 +1,31 @@
-package file
+package binlog
 
 import (
 	"fmt"
 	"os"
 	"path"
-	
-	"github.com/ngaut/log"
+
 	"github.com/pingcap/tidb-binlog/util/fileutil"
 )
 
-//pipe for generate new file
+// pipe for generate new file
 type filePipeline struct {
-	dir 	string
-	size	string
-	count	int // just for temporary filename 
+	dir   string
+	opts  *Options
+	count int // just for temporary filename
 
-	filec 	chan *fileutil.LockedFile
-	errc	chan error
-	donec	chan struct{}
+	filec chan *fileutil.LockedFile
+	errc  chan error
+	donec chan struct{}
 }
 
-func newFilePipeline(dir string, fileSize int64) *filePipeline {
-	fp := &filePipeline {
-		dir:	dir,
-		size:	fileSize,
-		filec:	make(chan *fileutil.LockedFile),
-		errc:	make(chan error, 1),
-		donec:	make(chan struct{})
+func newFilePipeline(dir string, opts *Options) *filePipeline {
+	fp := &filePipeline{
+		dir:   dir,
+		opts:  opts,
+		filec: make(chan *fileutil.LockedFile),
+		errc:  make(chan error, 1),
+		donec: make(chan struct{}),
 	}
 
 	go fp.run()
@@ -35,8 +34,8 @@ func newFilePipeline(dir string, fileSize int64) *filePipeline {
 
 func (fp *filePipeline) Open() (f *fileutil.LockedFile, err error) {
 	select {
-	case f = <- fp.filec:
-	case err = <- fp.errc:
+	case f = <-fp.filec:
+	case err = <-fp.errc:
 	}
 
 	return f, err
@@ -49,14 +48,16 @@ func (fp *filePipeline) Close() error {
 
 func (fp *filePipeline) alloc() (f *fileutil.LockedFile, err error) {
 	fpath := path.Join(fp.dir, fmt.Sprintf("%d.tmp", fp.count%2))
-	if f, err = fileutil.LockFile(fpath, os.O_CREATE|os.O_WRONLY. fileutil.PrivateFileMode); err != nil {
+	if f, err = fileutil.LockFile(fpath, os.O_CREATE|os.O_WRONLY, fp.opts.FileMode); err != nil {
 		return nil, err
 	}
 
-	if err = fileutil.Preallocate(f.File, fp.size, true); err != nil {
-		log.Errorf("failed to allocate space when creating new wal file (%v)"), err)
-		f.Close()
-		return nil, err
+	if fp.opts.Preallocate {
+		if err = fileutil.Preallocate(f.File, fp.opts.SegmentSize, true); err != nil {
+			fp.opts.Logger.Errorf("failed to allocate space when creating new binlog file (%v)", err)
+			f.Close()
+			return nil, err
+		}
 	}
 
 	fp.count++
@@ -74,7 +75,7 @@ func (fp *filePipeline) run() {
 
 		select {
 		case fp.filec <- f:
-		case <- fp.donec:
+		case <-fp.donec:
 			os.Remove(f.Name())
 			f.Close()
 			return
@@ -0,0 +1,265 @@
+package binlog
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// GCPolicy decides which binlog files are purged. files are purged from the
+// oldest one, as long as any of the set conditions matches; a zero field
+// disables its condition
+type GCPolicy struct {
+	// Retention purges the files which were last written before now - Retention
+	Retention time.Duration
+	// MaxTotalSize purges the oldest files until the directory fits in it
+	MaxTotalSize int64
+	// SafeCommitTs purges the files whose max CommitTs is below it
+	SafeCommitTs int64
+}
+
+// GC purges the old binlog files according to the policy and returns their
+// names, they are archived first if Options.Archive is set. the tail file,
+// the files from the oldest one in use by a reader of this process, and the
+// files locked by a writer of another process are never purged.
+// the readers of other processes are not known to GC: a file they have
+// opened stays readable until they close it, since removing it only
+// unlinks its name, but a file they are yet to open may be gone. keep
+// their positions with SafeCommitTs, e.g. from their checkpoints
+func (b *Binlog) GC(policy GCPolicy) ([]string, error) {
+	names, err := readBinlogNames(b.dir, b.opts.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, len(names))
+	var total int64
+	for i, name := range names {
+		if infos[i], err = os.Stat(path.Join(b.dir, name)); err != nil {
+			return nil, err
+		}
+		total += infos[i].Size()
+	}
+
+	// the last file is being written
+	candidates := names[:len(names)-1]
+
+	now := time.Now()
+	var purged []string
+	for i, name := range candidates {
+		index, err := parseBinlogName(name)
+		if err != nil {
+			return purged, err
+		}
+		if pinned(b.dir, index) {
+			break
+		}
+
+		ok, err := b.shouldPurge(policy, name, infos[i], total, now)
+		if err != nil {
+			return purged, err
+		}
+		if !ok {
+			break
+		}
+
+		if b.opts.Archive != nil {
+			// a file is never purged before it's archived
+			if err = archiveSegment(b.opts.Archive, b.dir, index, b.opts); err != nil {
+				return purged, err
+			}
+		}
+
+		ok, err = b.purgeUnpinned(name, index)
+		if err == fileutil.ErrLocked || (err == nil && !ok) {
+			break
+		}
+		if err != nil {
+			return purged, err
+		}
+
+		b.opts.Logger.Infof("purged binlog file %s", path.Join(b.dir, name))
+		total -= infos[i].Size()
+		purged = append(purged, name)
+	}
+
+	return purged, nil
+}
+
+// purgeUnpinned purges the binlog file if no reader of this process pins
+// it, the pins are held meanwhile so no reader can pin it in between.
+// it returns false if the file is pinned
+func (b *Binlog) purgeUnpinned(name string, index uint64) (bool, error) {
+	pins.Lock()
+	defer pins.Unlock()
+
+	if pinnedLocked(absDir(b.dir), index) {
+		return false, nil
+	}
+	return true, b.purge(name)
+}
+
+func (b *Binlog) shouldPurge(policy GCPolicy, name string, info os.FileInfo, total int64, now time.Time) (bool, error) {
+	if policy.Retention > 0 && now.Sub(info.ModTime()) > policy.Retention {
+		return true, nil
+	}
+
+	if policy.MaxTotalSize > 0 && total > policy.MaxTotalSize {
+		return true, nil
+	}
+
+	if policy.SafeCommitTs > 0 {
+		index, err := parseBinlogName(name)
+		if err != nil {
+			return false, err
+		}
+
+		meta, err := loadSegmentMeta(b.dir, index, false, b.opts)
+		if err != nil {
+			return false, err
+		}
+		return meta.MaxCommitTs < policy.SafeCommitTs, nil
+	}
+
+	return false, nil
+}
+
+// purge removes the binlog file and its sidecar files,
+// the binlog file must not be locked by others
+func (b *Binlog) purge(name string) error {
+	if err := b.removeBinlogFile(name); err != nil {
+		return err
+	}
+
+	index, err := parseBinlogName(name)
+	if err != nil {
+		return err
+	}
+
+	for _, sidecar := range []string{metaName(index), indexName(index)} {
+		err = os.Remove(path.Join(b.dir, sidecar))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Binlog) removeBinlogFile(name string) error {
+	p := path.Join(b.dir, name)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// a writer keeps the locks of the files it has cut
+	for i := 0; i < len(b.locks)-1; i++ {
+		l := b.locks[i]
+		if path.Base(l.Name()) != name {
+			continue
+		}
+
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		b.locks = append(b.locks[:i], b.locks[i+1:]...)
+		return l.Close()
+	}
+
+	l, err := fileutil.TryLockFile(p, os.O_WRONLY, b.opts.FileMode)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return os.Remove(p)
+}
+
+func (b *Binlog) startGCLoop() {
+	if b.opts.GCPolicy == nil || b.opts.GCInterval <= 0 {
+		return
+	}
+
+	policy := *b.opts.GCPolicy
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(b.opts.GCInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := b.GC(policy); err != nil {
+					b.opts.Logger.Errorf("failed to purge binlog files of %s: %v", b.dir, err)
+				}
+			case <-b.stopc:
+				return
+			}
+		}
+	}()
+}
+
+// pin marks the binlog files from index in use by a reader of this process
+type pin struct {
+	dir   string
+	index uint64
+}
+
+var pins = struct {
+	sync.Mutex
+	m map[*pin]struct{}
+}{m: make(map[*pin]struct{})}
+
+func pinFiles(dir string, index uint64) *pin {
+	p := &pin{dir: absDir(dir), index: index}
+
+	pins.Lock()
+	pins.m[p] = struct{}{}
+	pins.Unlock()
+
+	return p
+}
+
+// move moves the pin forward as the reader moves
+func (p *pin) move(index uint64) {
+	pins.Lock()
+	p.index = index
+	pins.Unlock()
+}
+
+func (p *pin) release() {
+	pins.Lock()
+	delete(pins.m, p)
+	pins.Unlock()
+}
+
+// pinned returns whether a reader of this process pins the binlog file
+// of index in the directory, that is any pin is at or before it
+func pinned(dir string, index uint64) bool {
+	pins.Lock()
+	defer pins.Unlock()
+
+	return pinnedLocked(absDir(dir), index)
+}
+
+func pinnedLocked(dir string, index uint64) bool {
+	for p := range pins.m {
+		if p.dir == dir && p.index <= index {
+			return true
+		}
+	}
+	return false
+}
+
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return path.Clean(dir)
+}
@@ -0,0 +1,206 @@
+package binlog
+
+import (
+	"io"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// gcTestOptions cuts a binlog file every 1KB
+func gcTestOptions() *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	opts.Preallocate = false
+	return opts
+}
+
+func binlogNames(t *testing.T, dir string) []string {
+	names, err := readBinlogNames(dir, defaultLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestGCSafeCommitTs(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 200, testEntry)
+
+	safe := int64(150)
+	purged, err := b.GC(GCPolicy{SafeCommitTs: safe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) == 0 {
+		t.Fatal("nothing is purged")
+	}
+
+	// the entries from the safe CommitTs are all kept
+	first, _ := parseBinlogName(binlogNames(t, dir)[0])
+	r, err := Open(dir, &binlogscheme.BinlogOffset{Index: first}, b.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ents, err := r.Read(1 << 20)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if ents[0].CommitTs >= safe || ents[len(ents)-1].CommitTs != 200 {
+		t.Fatalf("entries %d to %d are kept", ents[0].CommitTs, ents[len(ents)-1].CommitTs)
+	}
+
+	// the sidecar files are purged with their binlog files
+	for _, name := range purged {
+		index, _ := parseBinlogName(name)
+		for _, sidecar := range []string{name, metaName(index), indexName(index)} {
+			if fileutil.Exist(path.Join(dir, sidecar)) {
+				t.Fatalf("%s is left after purged", sidecar)
+			}
+		}
+	}
+}
+
+func TestGCMaxTotalSize(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 200, testEntry)
+
+	max := int64(4096)
+	if _, err := b.GC(GCPolicy{MaxTotalSize: max}); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	names := binlogNames(t, dir)
+	for _, name := range names {
+		info, err := os.Stat(path.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += info.Size()
+	}
+	if total > max {
+		t.Fatalf("%d bytes are kept in %d files, want at most %d", total, len(names), max)
+	}
+}
+
+func TestGCRetention(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 200, testEntry)
+
+	// the first 3 files are written a day ago
+	names := binlogNames(t, dir)
+	old := time.Now().Add(-24 * time.Hour)
+	for _, name := range names[:3] {
+		if err := os.Chtimes(path.Join(dir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := b.GC(GCPolicy{Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 3 || purged[2] != names[2] {
+		t.Fatalf("purged %v, want %v", purged, names[:3])
+	}
+}
+
+func TestGCPinnedReader(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 200, testEntry)
+	names := binlogNames(t, dir)
+
+	// a reader in the 3rd file keeps it and the ones after
+	pinnedIndex, _ := parseBinlogName(names[2])
+	r, err := Open(dir, &binlogscheme.BinlogOffset{Index: pinnedIndex}, b.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := b.GC(GCPolicy{SafeCommitTs: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 2 {
+		t.Fatalf("purged %v with the 3rd file pinned", purged)
+	}
+
+	ents, err := r.Read(1 << 20)
+	if err != io.EOF || len(ents) == 0 || ents[len(ents)-1].CommitTs != 200 {
+		t.Fatalf("read %d entries from a pinned file, %v", len(ents), err)
+	}
+	r.Close()
+
+	purged, err = b.GC(GCPolicy{SafeCommitTs: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != len(names)-3 {
+		t.Fatalf("purged %v after the reader is closed, want all but the tail", purged)
+	}
+}
+
+// TestGCRacingReaders opens readers from the first file while GC purges
+// it, a reader either fails to open or reads all the entries behind it
+func TestGCRacingReaders(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 500, testEntry)
+	names := binlogNames(t, dir)
+
+	var wg sync.WaitGroup
+	stopc := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stopc:
+					return
+				default:
+				}
+
+				r, err := b.NewReader(binlogscheme.BinlogOffset{Index: 0, Offset: segmentHeaderBytes})
+				if err == ErrFileNotFound || os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ents, err := r.Read(1 << 20)
+				r.Close()
+				if err != nil && err != io.EOF {
+					t.Errorf("read from a pinned file: %v", err)
+					return
+				}
+				if len(ents) != 500 {
+					t.Errorf("read %d entries from a pinned file", len(ents))
+					return
+				}
+			}
+		}()
+	}
+
+	var purged []string
+	for len(purged) == 0 {
+		p, err := b.GC(GCPolicy{SafeCommitTs: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		purged = append(purged, p...)
+	}
+	close(stopc)
+	wg.Wait()
+
+	if purged[0] != names[0] {
+		t.Fatalf("purged %v", purged)
+	}
+}
@@ -0,0 +1,198 @@
+package binlog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// commitRequest is the entries of one Write call waiting for group commit
+type commitRequest struct {
+	ents []binlogscheme.Entry
+	errc chan error
+}
+
+// groupCommitter batches the entries of concurrent Write calls,
+// so that they are encoded and synced together by one goroutine
+type groupCommitter struct {
+	maxBatch int
+	maxWait  time.Duration
+
+	// mu guards the intake, so no request is queued after
+	// stop and left without a result
+	mu     sync.RWMutex
+	closed bool
+
+	reqc  chan *commitRequest
+	stopc chan struct{}
+	donec chan struct{}
+}
+
+func newGroupCommitter(maxBatch int, maxWait time.Duration) *groupCommitter {
+	return &groupCommitter{
+		maxBatch: maxBatch,
+		maxWait:  maxWait,
+		reqc:     make(chan *commitRequest, maxBatch),
+		stopc:    make(chan struct{}),
+		donec:    make(chan struct{}),
+	}
+}
+
+// commit queues the entries and waits until they are durable
+func (gc *groupCommitter) commit(ents []binlogscheme.Entry) error {
+	req := &commitRequest{
+		ents: ents,
+		errc: make(chan error, 1),
+	}
+
+	// run keeps taking requests until stop holds the lock,
+	// so the send doesn't block stop forever
+	gc.mu.RLock()
+	if gc.closed {
+		gc.mu.RUnlock()
+		return ErrClosed
+	}
+	gc.reqc <- req
+	gc.mu.RUnlock()
+
+	return <-req.errc
+}
+
+func (gc *groupCommitter) run(b *Binlog) {
+	defer close(gc.donec)
+
+	for {
+		var req *commitRequest
+		select {
+		case req = <-gc.reqc:
+		case <-gc.stopc:
+			gc.drain(ErrClosed)
+			return
+		}
+
+		batch := []*commitRequest{req}
+		n := len(req.ents)
+		timer := time.NewTimer(gc.maxWait)
+	collect:
+		for n < gc.maxBatch {
+			select {
+			case req = <-gc.reqc:
+				batch = append(batch, req)
+				n += len(req.ents)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.commitBatch(batch)
+	}
+}
+
+// drain fails the requests which are still queued
+func (gc *groupCommitter) drain(err error) {
+	for {
+		select {
+		case req := <-gc.reqc:
+			req.errc <- err
+		default:
+			return
+		}
+	}
+}
+
+// stop fails the queued requests with ErrClosed and waits for run to
+// return, the batch being committed is finished first
+func (gc *groupCommitter) stop() {
+	gc.mu.Lock()
+	if !gc.closed {
+		gc.closed = true
+		close(gc.stopc)
+	}
+	gc.mu.Unlock()
+
+	<-gc.donec
+}
+
+// commitBatch writes all the entries of the batch with one sync,
+// then wakes up every waiting writer with its result
+func (b *Binlog) commitBatch(batch []*commitRequest) {
+	b.mu.Lock()
+	errs := b.commitBatchLocked(batch)
+	b.mu.Unlock()
+
+	for _, err := range errs {
+		if err == nil {
+			b.broadcast()
+			break
+		}
+	}
+
+	for i, req := range batch {
+		req.errc <- errs[i]
+	}
+}
+
+// commitBatchLocked returns the result of every request of the batch. the
+// entries of a request failing to encode leave nothing written, and the
+// rest of the batch goes on. a failed write or flush may take the frames
+// of the whole batch with it, so they are all rolled back and failed. a
+// failed sync or cut poisons the Binlog, the frames are left as they are
+func (b *Binlog) commitBatchLocked(batch []*commitRequest) []error {
+	errs := make([]error, len(batch))
+	if b.poisoned {
+		return failAll(errs, ErrPoisoned)
+	}
+	start := b.mark()
+
+	committed := false
+	for i, req := range batch {
+		errs[i] = b.encode(req.ents)
+		if errs[i] == nil {
+			committed = true
+			continue
+		}
+
+		if b.encoder.failed {
+			b.rollbackBatch(start)
+			return failAll(errs, errs[i])
+		}
+	}
+
+	if !committed {
+		return errs
+	}
+
+	// the bufio.Writer keeps a failed flush, so it's reset by the rollback
+	if err := b.encoder.flush(); err != nil {
+		b.rollbackBatch(start)
+		return failAll(errs, err)
+	}
+
+	if err := b.flushOrCut(); err != nil {
+		b.poison(err)
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+	return errs
+}
+
+// rollbackBatch rolls back the frames of a failed batch, the Binlog
+// is poisoned if the tail file can't be cut back to where it was
+func (b *Binlog) rollbackBatch(start writeMark) {
+	if err := b.rollback(start); err != nil {
+		b.opts.Logger.Errorf("failed to roll back binlog file %s to offset %d: %v", b.tail().Name(), start.offset.Offset, err)
+		b.poison(err)
+	}
+}
+
+func failAll(errs []error, err error) []error {
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
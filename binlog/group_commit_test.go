@@ -0,0 +1,360 @@
+package binlog
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func groupCommitTestOptions() *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 64 * 1024
+	opts.GroupCommitMaxBatch = 16
+	return opts
+}
+
+// readAt reads the entry at offset by a fresh reader of the directory
+func readAt(dir string, offset binlogscheme.BinlogOffset, opts *Options) (*binlogscheme.Entry, error) {
+	b, err := Open(dir, &offset, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	ents, err := b.Read(1)
+	if err != nil {
+		return nil, err
+	}
+	return &ents[0], nil
+}
+
+func TestGroupCommitConcurrentWriters(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := groupCommitTestOptions()
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	const writers, writes = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				ents := []binlogscheme.Entry{testEntry(w*writes + i)}
+				if err := b.Write(ents); err != nil {
+					t.Error(err)
+					return
+				}
+
+				// the entry is in the file once Write returns
+				ent, err := readAt(dir, ents[0].Offset, opts)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ent.CommitTs != ents[0].CommitTs {
+					t.Errorf("read CommitTs %d at %+v, want %d", ent.CommitTs, ents[0].Offset, ents[0].CommitTs)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	ents, err := readAll(dir, opts)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	for _, ent := range ents {
+		seen[ent.CommitTs] = true
+	}
+	if len(ents) != writers*writes || len(seen) != writers*writes {
+		t.Fatalf("read %d entries of %d CommitTs, want %d", len(ents), len(seen), writers*writes)
+	}
+}
+
+func TestGroupCommitClose(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, err := Create(dir, groupCommitTestOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 16
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				err := b.Write([]binlogscheme.Entry{testEntry(i)})
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writers hang after Close")
+	}
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	defer func(max int64) { maxRecordBytes = max }(maxRecordBytes)
+	maxRecordBytes = 64
+
+	for _, groupCommit := range []int{0, 16} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := groupCommitTestOptions()
+		opts.GroupCommitMaxBatch = groupCommit
+		b, err := Create(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(0)}); err != nil {
+			t.Fatal(err)
+		}
+		end := b.LastOffset()
+
+		// the entries before the large one are not written either
+		large := binlogscheme.Entry{CommitTs: 100, Payload: make([]byte, 100)}
+		err = b.Write([]binlogscheme.Entry{testEntry(1), testEntry(2), large})
+		if err != ErrEntryTooLarge {
+			t.Fatalf("write a large entry: %v", err)
+		}
+		if b.LastOffset() != end {
+			t.Fatalf("data ends at %+v after a failed write, want %+v", b.LastOffset(), end)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(3)}); err != nil {
+			t.Fatal(err)
+		}
+		b.Close()
+
+		ents, err := readAll(dir, opts)
+		if err != io.EOF || len(ents) != 2 || ents[0].CommitTs != testEntry(0).CommitTs || ents[1].CommitTs != testEntry(3).CommitTs {
+			t.Fatalf("group commit %d: read %+v, %v", groupCommit, ents, err)
+		}
+	}
+}
+
+func TestGroupCommitFailedRequest(t *testing.T) {
+	defer func(max int64) { maxRecordBytes = max }(maxRecordBytes)
+	maxRecordBytes = 64
+
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := groupCommitTestOptions()
+	opts.GroupCommitMaxWait = 50 * time.Millisecond
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the requests are likely batched together, the large one fails alone
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ent := testEntry(i)
+			if i == 3 {
+				ent.Payload = make([]byte, 100)
+			}
+			errs[i] = b.Write([]binlogscheme.Entry{ent})
+		}(i)
+	}
+	wg.Wait()
+	b.Close()
+
+	for i, err := range errs {
+		if (i == 3) != (err == ErrEntryTooLarge) || (i != 3 && err != nil) {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	ents, err := readAll(dir, opts)
+	if err != io.EOF || len(ents) != len(errs)-1 {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+}
+
+// failingWriter writes the first n bytes to w and fails the rest
+type failingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) <= f.n {
+		f.n -= len(p)
+		return f.w.Write(p)
+	}
+
+	n, _ := f.w.Write(p[:f.n])
+	f.n = 0
+	return n, errors.New("disk is full")
+}
+
+func TestWriteRollback(t *testing.T) {
+	for _, groupCommit := range []int{0, 16} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := groupCommitTestOptions()
+		opts.GroupCommitMaxBatch = groupCommit
+		opts.WriteBufferSize = 16
+		b, err := Create(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(0)}); err != nil {
+			t.Fatal(err)
+		}
+		end := b.LastOffset()
+
+		// part of the frames reach the file before the write fails
+		b.mu.Lock()
+		tail := b.encoder.w
+		b.encoder.w = &failingWriter{w: tail, n: 40}
+		b.encoder.reset()
+		b.mu.Unlock()
+
+		err = b.Write([]binlogscheme.Entry{testEntry(1), testEntry(2)})
+		if err == nil {
+			t.Fatal("write to a full disk")
+		}
+		if b.LastOffset() != end {
+			t.Fatalf("data ends at %+v after a failed write, want %+v", b.LastOffset(), end)
+		}
+
+		b.mu.Lock()
+		b.encoder.w = tail
+		b.encoder.reset()
+		b.mu.Unlock()
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(3)}); err != nil {
+			t.Fatal(err)
+		}
+		b.Close()
+
+		ents, err := readAll(dir, opts)
+		if err != io.EOF || len(ents) != 2 || ents[1].CommitTs != testEntry(3).CommitTs {
+			t.Fatalf("group commit %d: read %+v, %v", groupCommit, ents, err)
+		}
+	}
+}
+
+func TestFlushRollback(t *testing.T) {
+	for _, groupCommit := range []int{0, 16} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := groupCommitTestOptions()
+		opts.GroupCommitMaxBatch = groupCommit
+		b, err := Create(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(0)}); err != nil {
+			t.Fatal(err)
+		}
+		end := b.LastOffset()
+
+		// the frames fit in the buffer, so the write fails at the flush
+		b.mu.Lock()
+		tail := b.encoder.w
+		b.encoder.w = &failingWriter{w: tail, n: 0}
+		b.encoder.reset()
+		b.mu.Unlock()
+
+		err = b.Write([]binlogscheme.Entry{testEntry(1), testEntry(2)})
+		if err == nil {
+			t.Fatal("flush to a full disk")
+		}
+		if b.LastOffset() != end {
+			t.Fatalf("data ends at %+v after a failed flush, want %+v", b.LastOffset(), end)
+		}
+
+		b.mu.Lock()
+		b.encoder.w = tail
+		b.encoder.reset()
+		b.mu.Unlock()
+
+		// the failed flush is not kept by the buffer
+		if err = b.Write([]binlogscheme.Entry{testEntry(3)}); err != nil {
+			t.Fatal(err)
+		}
+		b.Close()
+
+		ents, err := readAll(dir, opts)
+		if err != io.EOF || len(ents) != 2 || ents[1].CommitTs != testEntry(3).CommitTs {
+			t.Fatalf("group commit %d: read %+v, %v", groupCommit, ents, err)
+		}
+	}
+}
+
+func TestSyncPoison(t *testing.T) {
+	for _, groupCommit := range []int{0, 16} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := groupCommitTestOptions()
+		opts.GroupCommitMaxBatch = groupCommit
+		b, err := Create(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(0)}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the fsync fails on the closed file
+		b.mu.Lock()
+		err = b.tail().File.Close()
+		b.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = b.Sync(); err == nil || err == ErrPoisoned {
+			t.Fatalf("sync a closed file: %v", err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(1)}); err != ErrPoisoned {
+			t.Fatalf("group commit %d: write after a failed sync: %v", groupCommit, err)
+		}
+		if err = b.Sync(); err != ErrPoisoned {
+			t.Fatalf("group commit %d: sync after a failed sync: %v", groupCommit, err)
+		}
+		b.Close()
+
+		ents, err := readAll(dir, opts)
+		if err != io.EOF || len(ents) != 1 {
+			t.Fatalf("group commit %d: read %+v, %v", groupCommit, ents, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// every binlog file begins with a fixed header
+// | magic(4) | version(2) | flags(2) | index(8) | create time(8) | key id(4) | crc(4) |
+// flags: bits 0-1 checksum algorithm of the frames, bits 2-3 compression
+// of the writer, bit 4 whether the frames are encrypted with the key of
+// key id. the crc covers the bytes before it
+const (
+	segmentMagic       = "TBLG"
+	segmentVersion     = 1
+	segmentHeaderBytes = 32
+
+	checksumCRC32C = 1
+
+	headerEncrypted = 1 << 4
+)
+
+// SegmentHeaderError is returned when a binlog file has a bad
+// or unsupported header
+type SegmentHeaderError struct {
+	Index  uint64
+	Reason string
+}
+
+func (e *SegmentHeaderError) Error() string {
+	return fmt.Sprintf("binlog: bad header of binlog file %s: %s", fileName(e.Index), e.Reason)
+}
+
+type segmentHeader struct {
+	Version     uint16
+	Checksum    byte
+	Compression Compression
+	Encrypted   bool
+	Index       uint64
+	CreateTime  time.Time
+	KeyID       uint32
+}
+
+// newSegmentHeader returns the header of a new binlog file,
+// which is encrypted with the current key if there is a key provider
+func newSegmentHeader(index uint64, opts *Options) (*segmentHeader, error) {
+	h := &segmentHeader{
+		Version:     segmentVersion,
+		Checksum:    checksumCRC32C,
+		Compression: opts.Compression,
+		Index:       index,
+		CreateTime:  time.Now(),
+	}
+
+	if opts.KeyProvider != nil {
+		id, _, err := opts.KeyProvider.CurrentKey()
+		if err != nil {
+			return nil, err
+		}
+		h.Encrypted, h.KeyID = true, id
+	}
+
+	return h, nil
+}
+
+func (h *segmentHeader) marshal() []byte {
+	b := make([]byte, segmentHeaderBytes)
+	copy(b[0:4], segmentMagic)
+	binary.LittleEndian.PutUint16(b[4:6], h.Version)
+	flags := uint16(h.Checksum&0x3) | uint16(h.Compression&compressionMask)<<2
+	if h.Encrypted {
+		flags |= headerEncrypted
+	}
+	binary.LittleEndian.PutUint16(b[6:8], flags)
+	binary.LittleEndian.PutUint64(b[8:16], h.Index)
+	binary.LittleEndian.PutUint64(b[16:24], uint64(h.CreateTime.UnixNano()))
+	binary.LittleEndian.PutUint32(b[24:28], h.KeyID)
+	binary.LittleEndian.PutUint32(b[28:32], crc32.Checksum(b[:28], crcTable))
+	return b
+}
+
+// unmarshalSegmentHeader decodes and validates the header of the binlog file index
+func unmarshalSegmentHeader(b []byte, index uint64) (*segmentHeader, error) {
+	if string(b[0:4]) != segmentMagic {
+		return nil, &SegmentHeaderError{Index: index, Reason: "bad magic, it's not a binlog file or written by an old version"}
+	}
+
+	if crc := crc32.Checksum(b[:28], crcTable); crc != binary.LittleEndian.Uint32(b[28:32]) {
+		return nil, &SegmentHeaderError{Index: index, Reason: "crc mismatch"}
+	}
+
+	flags := binary.LittleEndian.Uint16(b[6:8])
+	h := &segmentHeader{
+		Version:     binary.LittleEndian.Uint16(b[4:6]),
+		Checksum:    byte(flags & 0x3),
+		Compression: Compression((flags >> 2) & compressionMask),
+		Encrypted:   flags&headerEncrypted != 0,
+		Index:       binary.LittleEndian.Uint64(b[8:16]),
+		CreateTime:  time.Unix(0, int64(binary.LittleEndian.Uint64(b[16:24]))),
+		KeyID:       binary.LittleEndian.Uint32(b[24:28]),
+	}
+
+	if h.Version != segmentVersion {
+		return nil, &SegmentHeaderError{Index: index, Reason: fmt.Sprintf("unsupported format version %d, expect %d", h.Version, segmentVersion)}
+	}
+	if h.Checksum != checksumCRC32C {
+		return nil, &SegmentHeaderError{Index: index, Reason: fmt.Sprintf("unsupported checksum algorithm %d", h.Checksum)}
+	}
+	if h.Index != index {
+		return nil, &SegmentHeaderError{Index: index, Reason: fmt.Sprintf("mismatched index %d", h.Index)}
+	}
+
+	return h, nil
+}
+
+func readSegmentHeader(r io.Reader, index uint64) (*segmentHeader, error) {
+	b := make([]byte, segmentHeaderBytes)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &SegmentHeaderError{Index: index, Reason: "file is too short"}
+		}
+		return nil, err
+	}
+
+	return unmarshalSegmentHeader(b, index)
+}
+
+// checkSegmentHeader validates the header of the opened binlog file
+// without moving its offset
+func checkSegmentHeader(f io.ReaderAt, index uint64) (*segmentHeader, error) {
+	return readSegmentHeader(io.NewSectionReader(f, 0, segmentHeaderBytes), index)
+}
+
+// loadSegmentHeader reads and validates the header of the binlog file
+func loadSegmentHeader(dir string, index uint64) (*segmentHeader, error) {
+	f, err := os.Open(path.Join(dir, fileName(index)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readSegmentHeader(f, index)
+}
+
+func writeSegmentHeader(w io.Writer, index uint64, opts *Options) (*segmentHeader, error) {
+	h, err := newSegmentHeader(index, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// sealer returns the sealer which the frames of the binlog file are
+// encrypted with, it's nil if the binlog file is not encrypted
+func (h *segmentHeader) sealer(opts *Options) (*sealer, error) {
+	if !h.Encrypted {
+		return nil, nil
+	}
+	if opts.KeyProvider == nil {
+		return nil, ErrNoKeyProvider
+	}
+
+	key, err := opts.KeyProvider.Key(h.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("binlog: failed to get key %d of binlog file %s: %v", h.KeyID, fileName(h.Index), err)
+	}
+	return newSealer(h.KeyID, key, h)
+}
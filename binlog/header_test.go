@@ -0,0 +1,106 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func TestSegmentHeader(t *testing.T) {
+	h := &segmentHeader{
+		Version:     segmentVersion,
+		Checksum:    checksumCRC32C,
+		Compression: CompressionZstd,
+		Encrypted:   true,
+		Index:       7,
+		CreateTime:  time.Unix(0, 1234567890),
+		KeyID:       3,
+	}
+	b := h.marshal()
+	if len(b) != segmentHeaderBytes || string(b[:4]) != segmentMagic {
+		t.Fatalf("header of %d bytes begins with %q", len(b), b[:4])
+	}
+
+	got, err := unmarshalSegmentHeader(b, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *h {
+		t.Fatalf("header is decoded as %+v, want %+v", got, h)
+	}
+}
+
+func TestSegmentHeaderErrors(t *testing.T) {
+	good := (&segmentHeader{Version: segmentVersion, Checksum: checksumCRC32C, Index: 1}).marshal()
+	// resum fixes the crc after a field is changed
+	resum := func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[28:32], crc32.Checksum(b[:28], crcTable))
+		return b
+	}
+	edit := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), good...))
+	}
+
+	for reason, b := range map[string][]byte{
+		"bad magic":    edit(func(b []byte) []byte { b[0] = 'X'; return b }),
+		"crc mismatch": edit(func(b []byte) []byte { b[20]++; return b }),
+		"unsupported format version 2": edit(func(b []byte) []byte {
+			binary.LittleEndian.PutUint16(b[4:6], 2)
+			return resum(b)
+		}),
+		"unsupported checksum algorithm 2": edit(func(b []byte) []byte {
+			b[6] = b[6]&^0x3 | 2
+			return resum(b)
+		}),
+		"mismatched index 1": good,
+	} {
+		_, err := unmarshalSegmentHeader(b, 2)
+		herr, ok := err.(*SegmentHeaderError)
+		if !ok || herr.Index != 2 || !strings.HasPrefix(herr.Reason, reason) {
+			t.Fatalf("header with %s: %v", reason, err)
+		}
+	}
+
+	if _, err := unmarshalSegmentHeader(good, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenBadHeader(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	writeTestBinlog(t, dir, opts, 100)
+
+	// a binlog file of a future version is refused, not misread
+	f, err := os.OpenFile(path.Join(dir, fileName(2)), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, segmentHeaderBytes)
+	if _, err = f.ReadAt(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint16(b[4:6], segmentVersion+1)
+	binary.LittleEndian.PutUint32(b[28:32], crc32.Checksum(b[:28], crcTable))
+	_, err = f.WriteAt(b, 0)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+	if err == nil {
+		_, err = r.Read(1000)
+		r.Close()
+	}
+	if herr, ok := err.(*SegmentHeaderError); !ok || herr.Index != 2 {
+		t.Fatalf("read over a header of a future version: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+const indexEntryBytes = 16
+
+var ErrBadOffset = errors.New("binlog: offset is not at the start of an entry")
+
+// indexEntry is one record of the sparse index of a binlog file, CommitTs is
+// the max CommitTs of the entries before Offset. it never decreases along
+// the index even if the entries are not written in CommitTs order, so the
+// index can be binary searched for the entries of any ts.
+// a sealed binlog file's index ends with the entry of its data end
+type indexEntry struct {
+	Offset   int64
+	CommitTs int64
+}
+
+// the index file name format is like 0000000000000001.idx
+func indexName(index uint64) string {
+	return fmt.Sprintf("%016d.idx", index)
+}
+
+// searchIndexEntry returns the offset from which every entry whose
+// CommitTs >= ts can be found by decoding forward
+func searchIndexEntry(idx []indexEntry, ts int64) int64 {
+	i := sort.Search(len(idx), func(i int) bool { return idx[i].CommitTs >= ts })
+	if i == 0 {
+		return 0
+	}
+	return idx[i-1].Offset
+}
+
+// floorIndexEntry returns the last indexed offset not behind offset
+func floorIndexEntry(idx []indexEntry, offset int64) int64 {
+	i := sort.Search(len(idx), func(i int) bool { return idx[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return idx[i-1].Offset
+}
+
+// indexBuilder collects the index entries while a binlog file is
+// written or decoded
+type indexBuilder struct {
+	interval int64
+	last     int64
+	entries  []indexEntry
+}
+
+// add is called before the entry at offset is written, meta is the meta of
+// the entries before it. it returns whether an index entry is added
+func (ib *indexBuilder) add(offset int64, meta *segmentMeta) bool {
+	if meta.Entries == 0 || offset-ib.last < ib.interval {
+		return false
+	}
+
+	ib.last = offset
+	ib.entries = append(ib.entries, indexEntry{Offset: offset, CommitTs: meta.MaxCommitTs})
+	return true
+}
+
+func encodeIndexEntry(buf []byte, e indexEntry) {
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(e.Offset))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(e.CommitTs))
+}
+
+// decodeIndex decodes the index entries, a partial entry at the end is
+// dropped, and so are the entries that break the order or are behind size
+func decodeIndex(data []byte, size int64) []indexEntry {
+	idx := make([]indexEntry, 0, len(data)/indexEntryBytes)
+	for i := 0; i+indexEntryBytes <= len(data); i += indexEntryBytes {
+		e := indexEntry{
+			Offset:   int64(binary.LittleEndian.Uint64(data[i : i+8])),
+			CommitTs: int64(binary.LittleEndian.Uint64(data[i+8 : i+16])),
+		}
+		if e.Offset > size {
+			break
+		}
+		if n := len(idx); n > 0 && (e.Offset <= idx[n-1].Offset || e.CommitTs < idx[n-1].CommitTs) {
+			break
+		}
+		idx = append(idx, e)
+	}
+	return idx
+}
+
+// loadSegmentIndex returns the index of the binlog file whose data ends at
+// size. the index of a sealed binlog file is rebuilt in memory if its index
+// file is missing or doesn't end at size, only the writer writes it back.
+// for the tail binlog file, which is still written, the valid part of its
+// index is returned
+func loadSegmentIndex(dir string, index uint64, size int64, tail bool, opts *Options) ([]indexEntry, error) {
+	idx, complete, err := readSegmentIndex(dir, index, size)
+	if err != nil {
+		return nil, err
+	}
+	if tail || complete {
+		return idx, nil
+	}
+
+	if len(idx) > 0 {
+		opts.Logger.Warningf("index of binlog file %s is stale", fileName(index))
+	}
+	_, idx, err = scanSegment(dir, index, false, opts)
+	return idx, err
+}
+
+// readSegmentIndex reads the valid part of the index file, it's complete
+// if it ends with the entry of size, where the data of a sealed file ends
+func readSegmentIndex(dir string, index uint64, size int64) ([]indexEntry, bool, error) {
+	data, err := ioutil.ReadFile(path.Join(dir, indexName(index)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	idx := decodeIndex(data, size)
+	n := len(idx)
+	return idx, n > 0 && idx[n-1].Offset == size, nil
+}
+
+// writeSegmentIndex writes the whole index file atomically
+func writeSegmentIndex(dir string, index uint64, idx []indexEntry, opts *Options) error {
+	data := make([]byte, len(idx)*indexEntryBytes)
+	for i, e := range idx {
+		encodeIndexEntry(data[i*indexEntryBytes:], e)
+	}
+
+	p := path.Join(dir, indexName(index))
+	if err := ioutil.WriteFile(p+".tmp", data, opts.FileMode); err != nil {
+		return err
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+// checkOffset makes sure that offset is at the start of an entry
+// by decoding forward from the closest indexed offset
+func checkOffset(dir string, offset binlogscheme.BinlogOffset, tail bool, opts *Options) error {
+	if offset.Offset == 0 {
+		return nil
+	}
+	if offset.Offset < segmentHeaderBytes {
+		return ErrBadOffset
+	}
+
+	info, err := os.Stat(path.Join(dir, fileName(offset.Index)))
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if !tail {
+		meta, err := loadSegmentMeta(dir, offset.Index, false, opts)
+		if err != nil {
+			return err
+		}
+		size = meta.Size
+	}
+
+	idx, err := loadSegmentIndex(dir, offset.Index, size, tail, opts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path.Join(dir, fileName(offset.Index)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the decoder reads the header together with the first entry
+	// when it starts from 0, so start behind the header instead
+	from := floorIndexEntry(idx, offset.Offset)
+	if from < segmentHeaderBytes {
+		from = segmentHeaderBytes
+	}
+	if _, err = f.Seek(from, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	pos := &binlogscheme.BinlogOffset{Index: offset.Index, Offset: from}
+	decoder := newDecoder(pos, opts, f)
+	ent := &binlogscheme.Entry{}
+	for pos.Offset < offset.Offset {
+		if err = decoder.decode(ent); err != nil {
+			if isTornOrEnd(err) {
+				return ErrBadOffset
+			}
+			return err
+		}
+	}
+
+	if pos.Offset != offset.Offset {
+		return ErrBadOffset
+	}
+	return nil
+}
+
+// indexWriter appends the index entries of the tail binlog file
+type indexWriter struct {
+	f  *os.File
+	bw *bufio.Writer
+	ib indexBuilder
+
+	buf [indexEntryBytes]byte
+}
+
+// newIndexWriter creates the index file of the tail binlog file
+// with the entries that are already built
+func newIndexWriter(dir string, index uint64, idx []indexEntry, opts *Options) (*indexWriter, error) {
+	if err := writeSegmentIndex(dir, index, idx, opts); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path.Join(dir, indexName(index)), os.O_WRONLY|os.O_APPEND, opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &indexWriter{
+		f:  f,
+		bw: bufio.NewWriter(f),
+		ib: indexBuilder{interval: opts.IndexInterval, entries: idx},
+	}
+	if n := len(idx); n > 0 {
+		w.ib.last = idx[n-1].Offset
+	}
+	return w, nil
+}
+
+// add is called before the entry at offset is written
+func (w *indexWriter) add(offset int64, meta *segmentMeta) error {
+	if !w.ib.add(offset, meta) {
+		return nil
+	}
+
+	encodeIndexEntry(w.buf[:], w.ib.entries[len(w.ib.entries)-1])
+	_, err := w.bw.Write(w.buf[:])
+	return err
+}
+
+func (w *indexWriter) flush() error {
+	return w.bw.Flush()
+}
+
+// seal ends the index with the entry of the data end and closes it
+func (w *indexWriter) seal(meta *segmentMeta) error {
+	encodeIndexEntry(w.buf[:], indexEntry{Offset: meta.Size, CommitTs: meta.MaxCommitTs})
+	if _, err := w.bw.Write(w.buf[:]); err != nil {
+		return err
+	}
+
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+
+	if err := fileutil.Fsync(w.f); err != nil {
+		return err
+	}
+
+	return w.f.Close()
+}
+
+func (w *indexWriter) close() error {
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+
+	return w.f.Close()
+}
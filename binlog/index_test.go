@@ -0,0 +1,113 @@
+package binlog
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func indexTestOptions() *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 64 * 1024
+	opts.IndexInterval = 512
+	return opts
+}
+
+func loadTestIndex(t *testing.T, dir string, index uint64, tail bool, opts *Options) []indexEntry {
+	meta, err := loadSegmentMeta(dir, index, tail, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := loadSegmentIndex(dir, index, meta.Size, tail, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestIndex(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := indexTestOptions()
+	b, offsets := createTestBinlog(t, dir, opts, 3000, evenTestEntry)
+	b.Close()
+
+	idx := loadTestIndex(t, dir, 0, false, opts)
+	if len(idx) < 10 {
+		t.Fatalf("%d index entries of a sealed binlog file", len(idx))
+	}
+	// the last entry marks the end of the data, the others are IndexInterval apart
+	for i := 1; i < len(idx)-1; i++ {
+		if idx[i].Offset-idx[i-1].Offset < opts.IndexInterval {
+			t.Fatalf("index entry %d at %d follows %d", i, idx[i].Offset, idx[i-1].Offset)
+		}
+	}
+
+	// the seeks go through the index
+	for ts, want := range map[int64]int64{0: 0, 1: 2, 777: 778, 1500: 1500, 5998: 5998} {
+		checkSeek(t, dir, opts, ts, want)
+	}
+
+	// a missing index file is rebuilt the same
+	if err := os.Remove(path.Join(dir, indexName(0))); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := loadTestIndex(t, dir, 0, false, opts)
+	if len(rebuilt) != len(idx) {
+		t.Fatalf("%d index entries rebuilt, want %d", len(rebuilt), len(idx))
+	}
+	for i := range idx {
+		if rebuilt[i] != idx[i] {
+			t.Fatalf("index entry %d rebuilt as %+v, want %+v", i, rebuilt[i], idx[i])
+		}
+	}
+	checkRestored(t, dir, indexName(0), opts)
+	if restored := loadTestIndex(t, dir, 0, false, opts); len(restored) != len(idx) {
+		t.Fatalf("%d index entries restored, want %d", len(restored), len(idx))
+	}
+
+	// the offsets are checked against the frames
+	r, err := Open(dir, &offsets[1234], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ents, _ := r.Read(1)
+	r.Close()
+	if len(ents) != 1 || ents[0].CommitTs != 2468 {
+		t.Fatalf("read %+v at %+v", ents, offsets[1234])
+	}
+	for _, offset := range []binlogscheme.BinlogOffset{offsets[1234], offsets[len(offsets)-1]} {
+		offset.Offset += 8
+		if _, err = Open(dir, &offset, opts); err != ErrBadOffset {
+			t.Fatalf("open in the middle of a frame at %+v: %v", offset, err)
+		}
+	}
+}
+
+func TestIndexTail(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := indexTestOptions()
+	opts.SegmentSize = 1 << 20
+	b, _ := createTestBinlog(t, dir, opts, 1000, evenTestEntry)
+	b.Close()
+
+	// the index of the tail is kept on appending after reopening
+	idx := loadTestIndex(t, dir, 0, true, opts)
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1000; i < 2000; i++ {
+		if err = b.Write([]binlogscheme.Entry{evenTestEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Close()
+
+	grown := loadTestIndex(t, dir, 0, true, opts)
+	if len(grown) <= len(idx) || grown[len(idx)-1] != idx[len(idx)-1] {
+		t.Fatalf("the tail index grows from %d to %d entries", len(idx), len(grown))
+	}
+	checkSeek(t, dir, opts, 2999, 3000)
+}
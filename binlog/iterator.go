@@ -0,0 +1,389 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// Iterator walks the entries of a binlog directory forward and backward,
+// it's at one entry after Next or Prev returns true
+type Iterator interface {
+	// Next moves to the next entry, it returns false at the end of the data
+	// or on an error. after the end, Prev moves to the last entry and Next
+	// tries again for the entries written since
+	Next() bool
+	// Prev moves to the previous entry, it returns false
+	// at the beginning of the data or on an error
+	Prev() bool
+	// Entry returns the current entry, it's valid until the next move
+	Entry() *binlogscheme.Entry
+	// Offset returns the offset of the current entry, or where Next
+	// goes if the iterator is not at an entry, it keeps the last one after Close
+	Offset() binlogscheme.BinlogOffset
+	Err() error
+	// Close releases the files, a closed iterator doesn't move,
+	// Next and Prev fail with ErrClosed then
+	Close() error
+}
+
+// iterSegment is an opened binlog file with the offsets of its frames
+type iterSegment struct {
+	index  uint64
+	f      segmentFile
+	frames []int64
+	// end is where the last scanned frame ends
+	end int64
+}
+
+// scan collects the offsets of the frames behind the scanned ones, it
+// only reads the lenFields, the frames are checked when they are decoded
+func (s *iterSegment) scan(opts *Options) error {
+	size, err := s.f.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+	if size <= s.end {
+		return nil
+	}
+
+	br := bufio.NewReaderSize(io.NewSectionReader(s.f, s.end, size-s.end), opts.ReadBufferSize)
+	var buf [8]byte
+	for {
+		if _, err = io.ReadFull(br, buf[:]); err != nil {
+			return nil
+		}
+
+		l := int64(binary.LittleEndian.Uint64(buf[:]))
+		if l == 0 {
+			return nil
+		}
+
+		recBytes, padBytes, _, _ := decodeFrameSize(l)
+		n := frameHeaderBytes + recBytes + padBytes
+		if s.end+n > size {
+			return nil
+		}
+		if _, err = br.Discard(int(n - 8)); err != nil {
+			return nil
+		}
+
+		s.frames = append(s.frames, s.end)
+		s.end += n
+	}
+}
+
+func (s *iterSegment) close() {
+	if s.f != nil {
+		s.f.Close()
+	}
+}
+
+type iterator struct {
+	dir  string
+	opts *Options
+	pin  *pin
+
+	// seg is nil after Close, closedAt is the Offset then
+	seg      *iterSegment
+	closed   bool
+	closedAt binlogscheme.BinlogOffset
+	// pos is the current frame of seg if valid, otherwise
+	// Next goes to pos and Prev goes to pos-1
+	pos   int
+	valid bool
+
+	decoder *decoder
+	offset  binlogscheme.BinlogOffset
+	ent     binlogscheme.Entry
+	// the payload of ent, it's reused by the next move
+	arena Arena
+	err   error
+}
+
+// NewIterator returns an Iterator before the entry at offset, so Next
+// moves to that entry and Prev moves to the one before it. the files
+// it's walking through are not purged by GC
+func (b *Binlog) NewIterator(offset binlogscheme.BinlogOffset) (Iterator, error) {
+	it := b.newIterator()
+	seg, err := it.load(offset.Index)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrFileNotFound
+		}
+		it.Close()
+		return nil, err
+	}
+	it.seg = seg
+
+	switch {
+	case offset.Offset == 0 || offset.Offset == segmentHeaderBytes:
+		it.pos = 0
+	case offset.Offset == seg.end:
+		it.pos = len(seg.frames)
+	default:
+		i := sort.Search(len(seg.frames), func(i int) bool { return seg.frames[i] >= offset.Offset })
+		if i == len(seg.frames) || seg.frames[i] != offset.Offset {
+			it.Close()
+			return nil, ErrBadOffset
+		}
+		it.pos = i
+	}
+
+	return it, nil
+}
+
+// NewReverseIterator returns an Iterator behind the last complete entry,
+// Prev walks from the newest entry backward
+func (b *Binlog) NewReverseIterator() (Iterator, error) {
+	names, err := readBinlogNames(b.dir, b.opts.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := parseBinlogName(names[len(names)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	it := b.newIterator()
+	if it.seg, err = it.load(last); err != nil {
+		it.Close()
+		return nil, err
+	}
+	it.pos = len(it.seg.frames)
+
+	return it, nil
+}
+
+func (b *Binlog) newIterator() *iterator {
+	it := &iterator{
+		dir:  b.dir,
+		opts: b.opts,
+		pin:  pinFiles(b.dir, 0),
+	}
+	// an entry is valid until the next move, which is
+	// before the file of it is closed
+	it.decoder = newDecoder(&it.offset, b.opts)
+	it.decoder.borrow = true
+	return it
+}
+
+// load opens the binlog file and scans its frames
+func (it *iterator) load(index uint64) (*iterSegment, error) {
+	// pin the file before opening it so it's not purged in between
+	it.pin.move(index)
+
+	sealed := it.sealed(index)
+	f, err := openSegmentFile(it.dir, index, sealed, it.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = checkSegmentHeader(f, index); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	seg := &iterSegment{index: index, f: f, end: segmentHeaderBytes}
+	if err = seg.scan(it.opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return seg, nil
+}
+
+// move makes seg the current segment
+func (it *iterator) move(seg *iterSegment) {
+	it.seg.close()
+	it.seg = seg
+}
+
+// sealed returns whether the binlog file is complete, which
+// is true once the next one is cut
+func (it *iterator) sealed(index uint64) bool {
+	return fileutil.Exist(path.Join(it.dir, fileName(index+1)))
+}
+
+// at decodes the frame i of the current segment. a torn frame at the end
+// of the tail file is dropped with the frames behind it and returns false
+// without error, they are scanned again when Next reaches the end
+func (it *iterator) at(i int) bool {
+	off := binlogscheme.BinlogOffset{Index: it.seg.index, Offset: it.seg.frames[i]}
+	it.arena.reset()
+	err := it.decoder.decodeAt(it.seg.f, off, &it.ent, &it.arena)
+	if err == nil {
+		it.pos, it.valid = i, true
+		return true
+	}
+
+	if isTornOrEnd(err) && !it.sealed(it.seg.index) {
+		it.seg.end = it.seg.frames[i]
+		it.seg.frames = it.seg.frames[:i]
+		return false
+	}
+
+	it.valid = false
+	it.err = err
+	return false
+}
+
+func (it *iterator) Next() bool {
+	if it.closed && it.err == nil {
+		it.err = ErrClosed
+	}
+	if it.err != nil {
+		return false
+	}
+
+	i := it.pos
+	if it.valid {
+		i++
+	}
+
+	for {
+		if i < len(it.seg.frames) {
+			if it.at(i) {
+				return true
+			}
+			if it.err != nil {
+				return false
+			}
+			continue
+		}
+
+		// the next file shows up after the current one is complete, so
+		// check it first and the frames scanned then are all of them
+		sealed := it.sealed(it.seg.index)
+		if it.err = it.seg.scan(it.opts); it.err != nil {
+			return false
+		}
+		if i < len(it.seg.frames) {
+			continue
+		}
+
+		if !sealed {
+			it.pos, it.valid = len(it.seg.frames), false
+			return false
+		}
+
+		seg, err := it.load(it.seg.index + 1)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.move(seg)
+		i = 0
+	}
+}
+
+func (it *iterator) Prev() bool {
+	if it.closed && it.err == nil {
+		it.err = ErrClosed
+	}
+	if it.err != nil {
+		return false
+	}
+
+	i := it.pos - 1
+	for {
+		if i >= len(it.seg.frames) {
+			i = len(it.seg.frames) - 1
+		}
+
+		if i >= 0 {
+			if it.at(i) {
+				return true
+			}
+			if it.err != nil {
+				return false
+			}
+			continue
+		}
+
+		index, ok, err := it.prevIndex()
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if !ok {
+			it.pos, it.valid = 0, false
+			return false
+		}
+
+		seg, err := it.load(index)
+		if os.IsNotExist(err) {
+			// purged after the names were read
+			it.pin.move(it.seg.index)
+			it.pos, it.valid = 0, false
+			return false
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.move(seg)
+		i = len(seg.frames) - 1
+	}
+}
+
+// prevIndex returns the index of the binlog file before the current one
+func (it *iterator) prevIndex() (uint64, bool, error) {
+	names, err := readBinlogNames(it.dir, it.opts.Logger)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		index, err := parseBinlogName(names[i])
+		if err != nil {
+			return 0, false, err
+		}
+		if index < it.seg.index {
+			return index, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (it *iterator) Entry() *binlogscheme.Entry {
+	if !it.valid {
+		return nil
+	}
+	return &it.ent
+}
+
+func (it *iterator) Offset() binlogscheme.BinlogOffset {
+	if it.seg == nil {
+		return it.closedAt
+	}
+
+	off := binlogscheme.BinlogOffset{Index: it.seg.index, Offset: it.seg.end}
+	if it.pos < len(it.seg.frames) {
+		off.Offset = it.seg.frames[it.pos]
+	}
+	return off
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	if it.seg != nil {
+		it.closedAt = it.Offset()
+		it.seg.close()
+		it.seg = nil
+	}
+	it.valid, it.closed = false, true
+	it.pin.release()
+	return nil
+}
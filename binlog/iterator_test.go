@@ -0,0 +1,131 @@
+package binlog
+
+import (
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func iteratorTestOptions() *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 2048
+	return opts
+}
+
+func TestIterator(t *testing.T) {
+	b, _ := createTestBinlog(t, path.Join(t.TempDir(), "binlog"), iteratorTestOptions(), 200, testEntry)
+
+	it, err := b.NewReverseIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	want := int64(200)
+	for it.Prev() {
+		if it.Entry().CommitTs != want {
+			t.Fatalf("prev is at %d, want %d", it.Entry().CommitTs, want)
+		}
+		want--
+	}
+	if it.Err() != nil || want != 0 {
+		t.Fatalf("prev stops before %d, %v", want, it.Err())
+	}
+
+	for i := 0; i < 200; i++ {
+		if !it.Next() || it.Entry().CommitTs != int64(i+1) {
+			t.Fatalf("next fails at entry %d, %v", i, it.Err())
+		}
+	}
+	if it.Next() {
+		t.Fatal("next moves past the end")
+	}
+
+	// it follows the entries written since
+	if err = b.Write([]binlogscheme.Entry{testEntry(200)}); err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() || it.Entry().CommitTs != 201 {
+		t.Fatalf("next misses the new entry, %v", it.Err())
+	}
+	off := it.Offset()
+	if off != it.Entry().Offset {
+		t.Fatalf("iterator is at %+v, the entry at %+v", off, it.Entry().Offset)
+	}
+	if !it.Prev() || it.Entry().CommitTs != 200 {
+		t.Fatalf("prev after following fails, %v", it.Err())
+	}
+}
+
+func TestIteratorAtOffset(t *testing.T) {
+	b, offsets := createTestBinlog(t, path.Join(t.TempDir(), "binlog"), iteratorTestOptions(), 100, testEntry)
+	off := offsets[50]
+
+	it, err := b.NewIterator(off)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	if !it.Prev() || it.Entry().CommitTs != 50 {
+		t.Fatalf("prev from %+v fails, %v", off, it.Err())
+	}
+	if !it.Next() || it.Entry().CommitTs != 51 {
+		t.Fatalf("next from %+v fails, %v", off, it.Err())
+	}
+
+	if _, err = b.NewIterator(binlogscheme.BinlogOffset{Index: off.Index, Offset: off.Offset + 3}); err != ErrBadOffset {
+		t.Fatalf("iterator at a bad offset: %v", err)
+	}
+	if _, err = b.NewIterator(binlogscheme.BinlogOffset{Index: 100}); err != ErrFileNotFound {
+		t.Fatalf("iterator in a missing file: %v", err)
+	}
+}
+
+func TestIteratorTornTail(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, iteratorTestOptions(), 10, testEntry)
+
+	end := b.LastOffset()
+	writeAt(t, dir, end.Index, end.Offset, halfFrame(100))
+
+	it, err := b.NewReverseIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	if !it.Prev() || it.Entry().CommitTs != 10 {
+		t.Fatalf("prev over a torn frame fails, %v", it.Err())
+	}
+}
+
+func TestIteratorClose(t *testing.T) {
+	b, _ := createTestBinlog(t, path.Join(t.TempDir(), "binlog"), iteratorTestOptions(), 10, testEntry)
+
+	it, err := b.NewIterator(binlogscheme.BinlogOffset{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if !it.Next() {
+			t.Fatal(it.Err())
+		}
+	}
+	off := it.Offset()
+
+	if err = it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if it.Offset() != off {
+		t.Fatalf("offset after close is %+v, want %+v", it.Offset(), off)
+	}
+	if it.Err() != nil {
+		t.Fatalf("error %v after a normal close", it.Err())
+	}
+	if it.Next() || it.Prev() || it.Entry() != nil || it.Err() != ErrClosed {
+		t.Fatalf("a closed iterator moves, %v", it.Err())
+	}
+	if err = it.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,171 @@
+package binlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// segmentMeta summarizes the entries of one binlog file, it's kept in a
+// %016d.meta file beside a sealed binlog file so that the readers can skip
+// whole files by CommitTs without decoding them
+type segmentMeta struct {
+	Index       uint64 `json:"index"`
+	Entries     int64  `json:"entries"`
+	MinCommitTs int64  `json:"min-commit-ts"`
+	MaxCommitTs int64  `json:"max-commit-ts"`
+	// Size is where the data of the binlog file ends
+	Size int64 `json:"size"`
+}
+
+func (m *segmentMeta) add(ent *binlogscheme.Entry) {
+	if m.Entries == 0 || ent.CommitTs < m.MinCommitTs {
+		m.MinCommitTs = ent.CommitTs
+	}
+	if m.Entries == 0 || ent.CommitTs > m.MaxCommitTs {
+		m.MaxCommitTs = ent.CommitTs
+	}
+	m.Entries++
+}
+
+// the meta file name format is like 0000000000000001.meta
+func metaName(index uint64) string {
+	return fmt.Sprintf("%016d.meta", index)
+}
+
+// writeSegmentMeta writes the meta file atomically
+func writeSegmentMeta(dir string, m *segmentMeta, opts *Options) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	p := path.Join(dir, metaName(m.Index))
+	if err = ioutil.WriteFile(p+".tmp", data, opts.FileMode); err != nil {
+		return err
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+// loadSegmentMeta returns the meta of the binlog file. the meta of a sealed
+// binlog file is rebuilt in memory if its meta file is missing or stale,
+// only the writer writes it back. the tail binlog file is still written
+// so it's always decoded
+func loadSegmentMeta(dir string, index uint64, tail bool, opts *Options) (*segmentMeta, error) {
+	info, err := os.Stat(path.Join(dir, fileName(index)))
+	if err != nil {
+		return nil, err
+	}
+
+	if !tail {
+		m, err := readSegmentMeta(dir, index, info.Size(), opts)
+		if m != nil || err != nil {
+			return m, err
+		}
+	}
+
+	m, _, err := scanSegment(dir, index, tail, opts)
+	return m, err
+}
+
+// readSegmentMeta reads the meta file of the sealed binlog file
+// of size bytes, it returns nil if the meta file is missing or stale
+func readSegmentMeta(dir string, index uint64, size int64, opts *Options) (*segmentMeta, error) {
+	data, err := ioutil.ReadFile(path.Join(dir, metaName(index)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &segmentMeta{}
+	if err = json.Unmarshal(data, m); err == nil && m.Index == index && m.Size == size {
+		return m, nil
+	}
+	opts.Logger.Warningf("meta of binlog file %s is stale", fileName(index))
+	return nil, nil
+}
+
+// restoreSidecars writes back the meta and index files of the sealed
+// binlog files which are missing or stale. it's only called by
+// OpenForWrite, the readers never write into the binlog directory
+func restoreSidecars(dir string, names []string, opts *Options) {
+	for _, name := range names {
+		index, err := parseBinlogName(name)
+		if err == nil {
+			err = restoreSidecar(dir, index, opts)
+		}
+		// they are rebuilt in memory by the readers anyway
+		if err != nil {
+			opts.Logger.Warningf("failed to restore the meta and index of binlog file %s: %v", name, err)
+		}
+	}
+}
+
+func restoreSidecar(dir string, index uint64, opts *Options) error {
+	info, err := os.Stat(path.Join(dir, fileName(index)))
+	if err != nil {
+		return err
+	}
+
+	m, err := readSegmentMeta(dir, index, info.Size(), opts)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		_, complete, err := readSegmentIndex(dir, index, m.Size)
+		if err != nil || complete {
+			return err
+		}
+	}
+
+	m, idx, err := scanSegment(dir, index, false, opts)
+	if err != nil {
+		return err
+	}
+	if err = writeSegmentMeta(dir, m, opts); err != nil {
+		return err
+	}
+	return writeSegmentIndex(dir, index, idx, opts)
+}
+
+// scanSegment decodes the whole binlog file for its meta and index,
+// a torn entry at the end of the tail file is taken as its end.
+// the index of a sealed file ends with the entry of its data end
+func scanSegment(dir string, index uint64, tail bool, opts *Options) (*segmentMeta, []indexEntry, error) {
+	f, err := os.Open(path.Join(dir, fileName(index)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	m := &segmentMeta{Index: index}
+	ib := &indexBuilder{interval: opts.IndexInterval}
+	offset := &binlogscheme.BinlogOffset{Index: index}
+	decoder := newDecoder(offset, opts, f)
+	ent := &binlogscheme.Entry{}
+	for {
+		start := offset.Offset
+		err = decoder.decode(ent)
+		if err == io.EOF || (tail && isTornOrEnd(err)) {
+			m.Size = offset.Offset
+			if !tail {
+				ib.entries = append(ib.entries, indexEntry{Offset: m.Size, CommitTs: m.MaxCommitTs})
+			}
+			return m, ib.entries, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ib.add(start, m)
+		m.add(ent)
+	}
+}
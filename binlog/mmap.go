@@ -0,0 +1,133 @@
+package binlog
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// segmentFile is a binlog file opened for reading
+type segmentFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// openSegmentFile opens the binlog file for reading, a sealed one is
+// mapped if Options.Mmap is set. it falls back to the buffered file if
+// the mapping fails
+func openSegmentFile(dir string, index uint64, sealed bool, opts *Options) (segmentFile, error) {
+	p := path.Join(dir, fileName(index))
+	f, err := os.OpenFile(p, os.O_RDONLY, opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Mmap || !sealed {
+		return f, nil
+	}
+
+	m, err := newMmapFile(f)
+	if err != nil {
+		opts.Logger.Warningf("failed to mmap binlog file %s, read it through buffers: %v", p, err)
+		return f, nil
+	}
+
+	f.Close()
+	return m, nil
+}
+
+// mmapFile is a sealed binlog file mapped read-only, the decoder reads
+// the frames straight from the mapping instead of through the buffers
+type mmapFile struct {
+	data []byte
+	off  int64
+}
+
+func newMmapFile(f *os.File) (*mmapFile, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// a sealed file always holds its header
+	if info.Size() < segmentHeaderBytes || int64(int(info.Size())) != info.Size() {
+		return nil, errors.New("binlog: bad size to mmap")
+	}
+
+	data, err := fileutil.Mmap(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &mmapFile{data: data}, nil
+}
+
+func (m *mmapFile) Read(p []byte) (int, error) {
+	if m.off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, m.data[m.off:])
+	m.off += int64(n)
+	return n, nil
+}
+
+// next returns the next n bytes of the mapping without copying them,
+// the returned slice can't be appended into the rest of the mapping
+func (m *mmapFile) next(n int) ([]byte, error) {
+	if m.off+int64(n) > int64(len(m.data)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := m.data[m.off : m.off+int64(n) : m.off+int64(n)]
+	m.off += int64(n)
+	return b, nil
+}
+
+func (m *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("binlog: negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mmapFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += m.off
+	case os.SEEK_END:
+		offset += int64(len(m.data))
+	default:
+		return 0, errors.New("binlog: bad whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("binlog: negative offset")
+	}
+	m.off = offset
+	return offset, nil
+}
+
+func (m *mmapFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+
+	err := fileutil.Munmap(m.data)
+	m.data = nil
+	return err
+}
@@ -0,0 +1,202 @@
+package binlog
+
+import (
+	"io"
+	"path"
+	"testing"
+	"unsafe"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func mmapTestOptions() *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 4096
+	opts.Mmap = true
+	return opts
+}
+
+// inMapping returns whether b points into the mapping of m
+func inMapping(m *mmapFile, b []byte) bool {
+	start := uintptr(unsafe.Pointer(&m.data[0]))
+	p := uintptr(unsafe.Pointer(&b[0]))
+	return p >= start && p < start+uintptr(len(m.data))
+}
+
+func TestMmapRead(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionSnappy} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := mmapTestOptions()
+		opts.Compression = c
+		writeTestBinlog(t, dir, opts, 500)
+
+		r, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, ok := r.decoder.brs[0].(*mmapFile)
+		if !ok {
+			t.Fatalf("%v: the sealed binlog file is not mapped", c)
+		}
+
+		ents, err := r.Read(10000)
+		if err != io.EOF || len(ents) != 500 {
+			t.Fatalf("%v: read %d entries, %v", c, len(ents), err)
+		}
+		for i, ent := range ents {
+			if string(ent.Payload) != string(testEntry(i).Payload) {
+				t.Fatalf("%v: entry %d has payload %q", c, i, ent.Payload)
+			}
+		}
+
+		// the payloads of Read are the caller's, they outlive the Binlog
+		if inMapping(m, ents[0].Payload) {
+			t.Fatalf("%v: the payload read points into the mapping", c)
+		}
+		if err = r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for i, ent := range ents {
+			ent.Payload[0] = 'x'
+			if string(ent.Payload[1:]) != string(testEntry(i).Payload[1:]) {
+				t.Fatalf("%v: entry %d has payload %q after Close", c, i, ent.Payload)
+			}
+		}
+	}
+}
+
+func TestMmapReadInto(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := mmapTestOptions()
+	// one sealed file, reading the headers of the next ones allocates
+	opts.SegmentSize = 1 << 20
+	writeTestBinlog(t, dir, opts, 500)
+	w, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.cut(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	r, err := Open(dir, &binlogscheme.BinlogOffset{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ents := make([]binlogscheme.Entry, 50)
+	a := &Arena{}
+	if _, err = r.ReadInto(ents, a); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(5, func() {
+		if _, err := r.ReadInto(ents, a); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 || a.off != 0 {
+		t.Fatalf("%v allocations and %d arena bytes per batch from the mapping", allocs, a.off)
+	}
+}
+
+func TestMmapReader(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := mmapTestOptions()
+	offsets := writeTestBinlog(t, dir, opts, 500)
+
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	r, err := b.NewReader(offsets[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the files are closed as the reader moves on, so it copies
+	var read []binlogscheme.Entry
+	ents := make([]binlogscheme.Entry, 50)
+	for {
+		n, err := r.ReadInto(ents, nil)
+		read = append(read, ents[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(read) != 497 {
+		t.Fatalf("read %d entries, want 497", len(read))
+	}
+	for i, ent := range read {
+		if string(ent.Payload) != string(testEntry(i+3).Payload) {
+			t.Fatalf("entry %d has payload %q", i+3, ent.Payload)
+		}
+	}
+}
+
+func TestMmapIterator(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := mmapTestOptions()
+	writeTestBinlog(t, dir, opts, 500)
+
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	it, err := b.NewReverseIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	i := 500
+	for it.Prev() {
+		i--
+		if string(it.Entry().Payload) != string(testEntry(i).Payload) {
+			t.Fatalf("entry %d has payload %q", i, it.Entry().Payload)
+		}
+	}
+	if it.Err() != nil || i != 0 {
+		t.Fatalf("prev stops at entry %d, %v", i, it.Err())
+	}
+}
+
+func TestMmapEncrypted(t *testing.T) {
+	tmp := t.TempDir()
+	dir := path.Join(tmp, "binlog")
+	opts := mmapTestOptions()
+	opts.KeyProvider = testKeyProvider(t, path.Join(tmp, "keys"), testKey1)
+	writeTestBinlog(t, dir, opts, 200)
+
+	// the records are opened in place, which the read-only mapping can't be
+	ents, err := readAll(dir, opts)
+	if err != io.EOF || len(ents) != 200 {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+	for i, ent := range ents {
+		if string(ent.Payload) != string(testEntry(i).Payload) {
+			t.Fatalf("entry %d has payload %q", i, ent.Payload)
+		}
+	}
+}
+
+func TestMmapCorruption(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := mmapTestOptions()
+	offsets := writeTestBinlog(t, dir, opts, 500)
+
+	bad := offsets[5]
+	flipPayload(t, dir, bad, 5)
+
+	ents, err := readAll(dir, opts)
+	if len(ents) != 5 || !IsCorruption(err) {
+		t.Fatalf("read %d entries, %v", len(ents), err)
+	}
+}
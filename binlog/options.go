@@ -0,0 +1,145 @@
+package binlog
+
+import (
+	"os"
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/binlog/storage"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// Logger is what a Binlog logs to, the default one writes to ngaut/log
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Infof(format string, args ...interface{})    { log.Infof(format, args...) }
+func (defaultLogger) Warningf(format string, args ...interface{}) { log.Warningf(format, args...) }
+func (defaultLogger) Errorf(format string, args ...interface{})   { log.Errorf(format, args...) }
+func (defaultLogger) Fatalf(format string, args ...interface{})   { log.Fatalf(format, args...) }
+
+// Options are the settings of one Binlog, so that several of them
+// in one process don't share any mutable state
+type Options struct {
+	// SegmentSize is the size at which the tail binlog file is cut
+	SegmentSize int64
+	// Preallocate makes every new binlog file preallocated to SegmentSize
+	Preallocate bool
+	// EncoderBufferSize is the buffer entries are marshaled into,
+	// larger entries are marshaled into a fresh slice
+	EncoderBufferSize int
+	// WriteBufferSize is the buffer in front of the tail binlog file
+	WriteBufferSize int
+	// ReadBufferSize is the buffer in front of every binlog file read
+	ReadBufferSize int
+	// FileMode is the permission of the created binlog files
+	FileMode os.FileMode
+	Logger   Logger
+
+	SyncPolicy SyncPolicy
+
+	// Compression is the codec of the new entries, an entry that doesn't
+	// shrink is written as it is. entries are always readable whatever it is
+	Compression Compression
+
+	// KeyProvider encrypts the new binlog files with AES-GCM by its current
+	// key, it's also needed to read the encrypted files. nil disables it
+	KeyProvider KeyProvider
+
+	// GroupCommitMaxBatch is the max number of entries that concurrent
+	// Write calls commit with one fsync, zero disables group commit
+	GroupCommitMaxBatch int
+	// GroupCommitMaxWait is how long a batch waits for more writers
+	GroupCommitMaxWait time.Duration
+
+	// IndexInterval is the bytes between two entries of the sparse index
+	IndexInterval int64
+
+	// Mmap makes the readers map the sealed binlog files read-only and
+	// decode from the mappings, the tail file is always read through buffers.
+	// the payloads read by ReadInto of a Binlog returned by Open into an
+	// Arena point into the mappings then, they are valid until the Binlog is
+	// closed and must not be modified. Read and the encrypted ones still copy
+	Mmap bool
+
+	// WatchInterval is how often Watch polls for the new entries
+	// which are not written through the watched Binlog
+	WatchInterval time.Duration
+
+	// GCPolicy and GCInterval start a background GC of a writing Binlog,
+	// a nil GCPolicy disables it
+	GCPolicy   *GCPolicy
+	GCInterval time.Duration
+
+	// Archive is where GC uploads the binlog files before purging them,
+	// nil disables archiving. see Restore for getting them back
+	Archive storage.Storage
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		SegmentSize:        64 * 1000 * 1000,
+		Preallocate:        true,
+		EncoderBufferSize:  1024 * 1024,
+		WriteBufferSize:    binlogPageBytes,
+		ReadBufferSize:     binlogPageBytes,
+		FileMode:           fileutil.PrivateFileMode,
+		Logger:             defaultLogger{},
+		SyncPolicy:         DefaultSyncPolicy,
+		GroupCommitMaxWait: 2 * time.Millisecond,
+		IndexInterval:      32 * 1024,
+		WatchInterval:      100 * time.Millisecond,
+		GCInterval:         time.Minute,
+	}
+}
+
+// normalize returns a copy of opts with the unset fields filled by the
+// defaults, a nil opts means DefaultOptions. Preallocate and
+// GroupCommitMaxBatch are taken as they are, start from DefaultOptions
+// to keep preallocation on
+func (opts *Options) normalize() *Options {
+	def := DefaultOptions()
+	if opts == nil {
+		return def
+	}
+
+	o := *opts
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = def.SegmentSize
+	}
+	if o.EncoderBufferSize <= 0 {
+		o.EncoderBufferSize = def.EncoderBufferSize
+	}
+	if o.WriteBufferSize <= 0 {
+		o.WriteBufferSize = def.WriteBufferSize
+	}
+	if o.ReadBufferSize <= 0 {
+		o.ReadBufferSize = def.ReadBufferSize
+	}
+	if o.FileMode == 0 {
+		o.FileMode = def.FileMode
+	}
+	if o.Logger == nil {
+		o.Logger = def.Logger
+	}
+	if o.GroupCommitMaxWait <= 0 {
+		o.GroupCommitMaxWait = def.GroupCommitMaxWait
+	}
+	if o.IndexInterval <= 0 {
+		o.IndexInterval = def.IndexInterval
+	}
+	if o.WatchInterval <= 0 {
+		o.WatchInterval = def.WatchInterval
+	}
+	if o.GCInterval <= 0 {
+		o.GCInterval = def.GCInterval
+	}
+
+	return &o
+}
@@ -0,0 +1,145 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// testLogger keeps what's logged for the tests to check
+type testLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) logf(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Infof(format string, args ...interface{})    { l.logf("info", format, args...) }
+func (l *testLogger) Warningf(format string, args ...interface{}) { l.logf("warning", format, args...) }
+func (l *testLogger) Errorf(format string, args ...interface{})   { l.logf("error", format, args...) }
+func (l *testLogger) Fatalf(format string, args ...interface{})   { l.logf("fatal", format, args...) }
+
+func (l *testLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOptionsNormalize(t *testing.T) {
+	def := DefaultOptions()
+	opts := (&Options{SegmentSize: 1024}).normalize()
+	if opts.SegmentSize != 1024 {
+		t.Fatalf("SegmentSize is %d, want 1024", opts.SegmentSize)
+	}
+	if opts.ReadBufferSize != def.ReadBufferSize || opts.FileMode != def.FileMode || opts.Logger == nil {
+		t.Fatalf("unset options are not defaulted: %+v", opts)
+	}
+	if opts.Preallocate {
+		t.Fatal("Preallocate is turned on by normalize")
+	}
+
+	if opts := (*Options)(nil).normalize(); opts.SegmentSize != def.SegmentSize || !opts.Preallocate {
+		t.Fatalf("nil options are not the default ones: %+v", opts)
+	}
+}
+
+func TestOptionsLogger(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	logger := &testLogger{}
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	opts.Logger = logger
+	writeTestBinlog(t, dir, opts, 20)
+
+	if !logger.contains("segmented binlog file") {
+		t.Fatal("the cut of the binlog file is not logged to Options.Logger")
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, "stray"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAll(dir, opts); err != io.EOF {
+		t.Fatal(err)
+	}
+	if !logger.contains("ignored file stray") {
+		t.Fatal("the stray file is not logged to Options.Logger")
+	}
+}
+
+func TestOptionsNotShared(t *testing.T) {
+	small := DefaultOptions()
+	small.SegmentSize = 1024
+	large := DefaultOptions()
+	large.SegmentSize = 1024 * 1024
+
+	smallDir := path.Join(t.TempDir(), "small")
+	largeDir := path.Join(t.TempDir(), "large")
+	writeTestBinlog(t, smallDir, small, 100)
+	writeTestBinlog(t, largeDir, large, 100)
+
+	smallNames, _ := readBinlogNames(smallDir, small.Logger)
+	largeNames, _ := readBinlogNames(largeDir, large.Logger)
+	if len(smallNames) < 2 || len(largeNames) != 1 {
+		t.Fatalf("%d small and %d large binlog files", len(smallNames), len(largeNames))
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	for _, groupCommit := range []int{0, 16} {
+		opts := DefaultOptions()
+		opts.SegmentSize = 1024
+		opts.GroupCommitMaxBatch = groupCommit
+		b, err := Create(path.Join(t.TempDir(), "binlog"), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err = b.Close(); err != nil {
+			t.Fatalf("close again: %v", err)
+		}
+
+		if err = b.Write([]binlogscheme.Entry{testEntry(0)}); err != ErrClosed {
+			t.Fatalf("group commit %d: write after close: %v", groupCommit, err)
+		}
+		if err = b.Sync(); err != ErrClosed {
+			t.Fatalf("sync after close: %v", err)
+		}
+	}
+}
+
+func TestWriteFillsOffset(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	b, err := Create(path.Join(t.TempDir(), "binlog"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ents := []binlogscheme.Entry{testEntry(0), testEntry(1)}
+	start := b.LastOffset()
+	if err = b.Write(ents); err != nil {
+		t.Fatal(err)
+	}
+	if ents[0].Offset != start || ents[1].Offset.Offset != start.Offset+testFrameBytes(0) {
+		t.Fatalf("entries are written at %+v and %+v from %+v", ents[0].Offset, ents[1].Offset, start)
+	}
+}
@@ -0,0 +1,111 @@
+package binlog
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// Reader reads the binlog directory of a Binlog with its own cursor and
+// file handles. the readers of one Binlog don't share any state with each
+// other nor with its writer, they only see the complete entries that are
+// flushed, and the files they are reading are not purged by GC
+type Reader struct {
+	mu     sync.Mutex
+	t      *tailer
+	closed bool
+}
+
+// NewReader returns a Reader from the offset, which must be the start of
+// an entry. the Reader must be closed after use
+func (b *Binlog) NewReader(offset binlogscheme.BinlogOffset) (*Reader, error) {
+	// pin the files before checking the offset, so they are not purged after
+	t := newTailer(b.dir, offset, b.opts)
+	if err := b.checkReaderOffset(offset); err != nil {
+		t.release()
+		return nil, err
+	}
+
+	return &Reader{t: t}, nil
+}
+
+func (b *Binlog) checkReaderOffset(offset binlogscheme.BinlogOffset) error {
+	names, err := readBinlogNames(b.dir, b.opts.Logger)
+	if err != nil {
+		return err
+	}
+
+	first, err := parseBinlogName(names[0])
+	if err != nil {
+		return err
+	}
+
+	last, err := parseBinlogName(names[len(names)-1])
+	if err != nil {
+		return err
+	}
+
+	// the reader can't start from a purged file nor a future one
+	if offset.Index < first || offset.Index > last {
+		return ErrFileNotFound
+	}
+
+	return checkOffset(b.dir, offset, offset.Index == last, b.opts)
+}
+
+// Read returns at most max entries, it returns io.EOF
+// if there is no new entry for now
+func (r *Reader) Read(max int) ([]binlogscheme.Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrClosed
+	}
+
+	ents, err := r.t.next(max)
+	if err == nil && len(ents) == 0 {
+		return nil, io.EOF
+	}
+	return ents, err
+}
+
+// ReadInto decodes at most len(ents) entries into ents and returns the
+// number of them, their payloads are allocated from the arena. it returns
+// io.EOF if there is no new entry for now
+func (r *Reader) ReadInto(ents []binlogscheme.Entry, a *Arena) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrClosed
+	}
+
+	a.reset()
+	n, err := r.t.nextInto(ents, a)
+	if err == nil && n == 0 && len(ents) > 0 {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+// Offset returns the offset of the next entry to read
+func (r *Reader) Offset() binlogscheme.BinlogOffset {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.t.offset
+}
+
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.t.release()
+	return nil
+}
@@ -0,0 +1,107 @@
+package binlog
+
+import (
+	"io"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// followReader reads n entries from r while they are written, and checks
+// their CommitTs are 0, 1, 2...
+func followReader(t *testing.T, r *Reader, n int) {
+	got := 0
+	deadline := time.Now().Add(10 * time.Second)
+	for got < n && time.Now().Before(deadline) {
+		ents, err := r.Read(7)
+		for _, ent := range ents {
+			if ent.CommitTs != int64(got) {
+				t.Errorf("read CommitTs %d, want %d", ent.CommitTs, got)
+				return
+			}
+			got++
+		}
+		if err == io.EOF {
+			time.Sleep(time.Millisecond)
+		} else if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if got != n {
+		t.Errorf("read %d entries, want %d", got, n)
+	}
+}
+
+func TestReaders(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 2048
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	const n = 300
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		r, err := b.NewReader(binlogscheme.BinlogOffset{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.Close()
+			followReader(t, r, n)
+		}()
+	}
+
+	// a reader closed early doesn't stop the others
+	closed, err := b.NewReader(binlogscheme.BinlogOffset{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = closed.Read(1); err != ErrClosed {
+		t.Fatalf("read from a closed reader: %v", err)
+	}
+
+	var mid binlogscheme.BinlogOffset
+	for i := 0; i < n; i++ {
+		ents := []binlogscheme.Entry{{CommitTs: int64(i), Payload: []byte("payload")}}
+		if err = b.Write(ents); err != nil {
+			t.Fatal(err)
+		}
+		if i == n/2 {
+			mid = ents[0].Offset
+		}
+	}
+	wg.Wait()
+
+	r, err := b.NewReader(mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ents, err := r.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 || ents[0].CommitTs != n/2 {
+		t.Fatalf("read %+v from %+v, want CommitTs %d", ents, mid, n/2)
+	}
+	if r.Offset() == mid {
+		t.Fatalf("the offset doesn't advance from %+v", mid)
+	}
+
+	if _, err = b.NewReader(binlogscheme.BinlogOffset{Index: mid.Index, Offset: mid.Offset + 1}); err != ErrBadOffset {
+		t.Fatalf("new reader from a bad offset: %v", err)
+	}
+}
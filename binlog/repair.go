@@ -0,0 +1,200 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// repairTail scans the binlog file f from the beginning, finds the end of
+// the last complete entry and truncates everything behind it. f is
+// preallocated with zeroes, so a crash may leave a half written frame
+// followed by zero padding; the padding is not counted as discarded.
+// only a torn frame is cut off, a bad frame followed by valid ones is a
+// corruption and returned as an error with the file left as it is.
+// it returns the meta and index of the complete entries, the Size of the
+// meta is the offset where the data ends, and the number of bytes cut off
+func repairTail(f *fileutil.LockedFile, dir string, index uint64, opts *Options) (*segmentMeta, []indexEntry, int64, error) {
+	meta, idx, err := scanSegment(dir, index, true, opts)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	rf, err := os.Open(f.Name())
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer rf.Close()
+
+	end, err := dataEnd(rf, meta.Size)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	discarded := end - meta.Size
+	if discarded == 0 {
+		return meta, idx, 0, nil
+	}
+
+	info, err := rf.Stat()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	torn, err := tornFrame(rf, meta.Size, end, info.Size())
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if !torn {
+		return nil, nil, 0, frameError(rf, binlogscheme.BinlogOffset{Index: index, Offset: meta.Size}, opts)
+	}
+
+	if err = f.Truncate(meta.Size); err != nil {
+		return nil, nil, 0, err
+	}
+
+	if opts.Preallocate {
+		if err = fileutil.Preallocate(f.File, opts.SegmentSize, true); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	if err = fileutil.Fsync(f.File); err != nil {
+		return nil, nil, 0, err
+	}
+
+	return meta, idx, discarded, nil
+}
+
+// tornFrame returns whether the frame at offset of the tail binlog file f,
+// which fails to decode, is torn by a crash or is still being written.
+// such a frame is the last one written, a bad frame followed by a valid one
+// is corrupted. the valid frames are looked for at the aligned positions
+// from nextFrame to end, f is of size bytes
+func tornFrame(f io.ReaderAt, offset, end, size int64) (bool, error) {
+	for pos := nextFrame(f, offset); pos < end; pos += 8 {
+		ok, err := validFrameAt(f, pos, size)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// nextFrame returns where the frame behind the bad one at offset should
+// start, so that the record of a torn frame is not taken for frames.
+// it's right behind the lenField if the lenField is bad too
+func nextFrame(f io.ReaderAt, offset int64) int64 {
+	var lenField [8]byte
+	if _, err := f.ReadAt(lenField[:], offset); err != nil {
+		return offset + 8
+	}
+
+	recBytes, padBytes, _, _ := decodeFrameSize(int64(binary.LittleEndian.Uint64(lenField[:])))
+	if !saneFrameSize(recBytes, padBytes) {
+		return offset + 8
+	}
+	return offset + frameHeaderBytes + recBytes + padBytes
+}
+
+// validFrameAt returns whether a complete frame passing its crc check is
+// at pos of f, it doesn't decrypt nor decode the record
+func validFrameAt(f io.ReaderAt, pos, size int64) (bool, error) {
+	var header [frameHeaderBytes]byte
+	if pos+frameHeaderBytes > size {
+		return false, nil
+	}
+	if _, err := f.ReadAt(header[:], pos); err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return false, err
+	}
+
+	l := int64(binary.LittleEndian.Uint64(header[:8]))
+	recBytes, padBytes, _, _ := decodeFrameSize(l)
+	if recBytes == 0 || !saneFrameSize(recBytes, padBytes) ||
+		pos+frameHeaderBytes+recBytes+padBytes > size {
+		return false, nil
+	}
+
+	record := make([]byte, recBytes)
+	if _, err := f.ReadAt(record, pos+frameHeaderBytes); err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return false, err
+	}
+
+	return crc32.Checksum(record, crcTable) == binary.LittleEndian.Uint32(header[8:]), nil
+}
+
+// frameError returns why the frame at offset of f fails to decode,
+// a zero lenField is reported as a corruption of the frame
+func frameError(f io.ReaderAt, offset binlogscheme.BinlogOffset, opts *Options) error {
+	var pos binlogscheme.BinlogOffset
+	err := newDecoder(&pos, opts).decodeAt(f, offset, &binlogscheme.Entry{}, nil)
+	if err == nil || err == io.EOF {
+		return &CorruptionError{Offset: offset}
+	}
+	return err
+}
+
+// zeroWord returns the position of the first aligned zero word behind
+// from, or size if there is none
+func zeroWord(f io.ReaderAt, from, size int64) (int64, error) {
+	if from >= size {
+		return size, nil
+	}
+
+	r := io.NewSectionReader(f, from, size-from)
+	pos := from
+	buf := make([]byte, 4096)
+	for {
+		n, err := io.ReadFull(r, buf)
+		for i := 0; i+8 <= n; i += 8 {
+			if binary.LittleEndian.Uint64(buf[i:]) == 0 {
+				return pos + int64(i), nil
+			}
+		}
+		pos += int64(n)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// dataEnd returns the position after the last non-zero byte behind from
+func dataEnd(f io.ReaderAt, from int64) (int64, error) {
+	r := io.NewSectionReader(f, from, math.MaxInt64-from)
+
+	end := from
+	pos := from
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] != 0 {
+				end = pos + int64(i) + 1
+				break
+			}
+		}
+		pos += int64(n)
+
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func repairTestOptions(preallocate bool) *Options {
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024 * 1024
+	opts.Preallocate = preallocate
+	return opts
+}
+
+// halfFrame returns the beginning of a frame of recBytes, as if the
+// write of it is torn by a crash
+func halfFrame(recBytes int) []byte {
+	lenField, _ := encodeFrameSize(recBytes, CompressionNone, false)
+	frame := make([]byte, 8, 8+crcBytes+recBytes/2)
+	binary.LittleEndian.PutUint64(frame, lenField)
+	frame = append(frame, 1, 2, 3, 4)
+	return append(frame, bytes.Repeat([]byte{'x'}, recBytes/2)...)
+}
+
+func openRepaired(t *testing.T, dir string, opts *Options, wantDiscarded int64, wantEnd binlogscheme.BinlogOffset) {
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if b.Discarded() != wantDiscarded {
+		t.Fatalf("discarded %d bytes, want %d", b.Discarded(), wantDiscarded)
+	}
+	if b.LastOffset() != wantEnd {
+		t.Fatalf("data ends at %+v, want %+v", b.LastOffset(), wantEnd)
+	}
+}
+
+func TestRepairZeroPadding(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	offsets := writeTestBinlog(t, dir, opts, 10)
+	end := offsets[9]
+	end.Offset += testFrameBytes(9)
+
+	// the preallocated zeroes are not torn data
+	openRepaired(t, dir, opts, 0, end)
+}
+
+func TestRepairTornTail(t *testing.T) {
+	for _, preallocate := range []bool{true, false} {
+		dir := path.Join(t.TempDir(), "binlog")
+		opts := repairTestOptions(preallocate)
+		writeTestBinlog(t, dir, opts, 10)
+
+		b, err := OpenForWrite(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := b.LastOffset()
+		b.Close()
+
+		torn := halfFrame(100)
+		writeAt(t, dir, end.Index, end.Offset, torn)
+		openRepaired(t, dir, opts, int64(len(torn)), end)
+
+		ents, err := readAll(dir, opts)
+		if err != io.EOF || len(ents) != 10 {
+			t.Fatalf("preallocate %v: read %d entries after repair, %v", preallocate, len(ents), err)
+		}
+	}
+}
+
+func TestRepairCorruptedLastFrame(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	offsets := writeTestBinlog(t, dir, opts, 10)
+
+	// nothing valid follows the last frame, it's torn whatever is wrong with it
+	last := offsets[9]
+	flipPayload(t, dir, last, 9)
+
+	// the zero padding of the frame is not counted
+	ent := testEntry(9)
+	openRepaired(t, dir, opts, frameHeaderBytes+int64(ent.MarshalSize()), last)
+}
+
+func TestRepairMidFileCorruption(t *testing.T) {
+	for name, corrupt := range map[string]func(t *testing.T, dir string, bad binlogscheme.BinlogOffset){
+		"bit flip": func(t *testing.T, dir string, bad binlogscheme.BinlogOffset) {
+			flipPayload(t, dir, bad, 5)
+		},
+		"zero length": func(t *testing.T, dir string, bad binlogscheme.BinlogOffset) {
+			writeAt(t, dir, bad.Index, bad.Offset, make([]byte, 8))
+		},
+		"bad length": func(t *testing.T, dir string, bad binlogscheme.BinlogOffset) {
+			writeAt(t, dir, bad.Index, bad.Offset+6, []byte{0x10})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "binlog")
+			opts := repairTestOptions(true)
+			offsets := writeTestBinlog(t, dir, opts, 10)
+
+			bad := offsets[5]
+			corrupt(t, dir, bad)
+			before, err := ioutil.ReadFile(path.Join(dir, fileName(bad.Index)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := OpenForWrite(dir, opts)
+			if err == nil {
+				b.Close()
+				t.Fatal("open a binlog corrupted in the middle of the tail file for writing")
+			}
+			if !IsCorruption(err) || err.(*CorruptionError).Offset != bad {
+				t.Fatalf("open a corrupted binlog: %v, want a corruption at %+v", err, bad)
+			}
+
+			// the valid frames behind the corruption are kept
+			after, err := ioutil.ReadFile(path.Join(dir, fileName(bad.Index)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Fatal("the corrupted binlog file is changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package binlog
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// OpenAtCommitTs opens the binlog directory for reading from the first entry
+// whose CommitTs >= ts. the files whose max CommitTs is below ts are skipped
+// by their meta, and the file holding the entry is binary searched by its
+// sparse index before decoding. if there is no such entry yet, it's opened at the end of
+// the data, so the reader gets the entries written later. opts may be nil
+func OpenAtCommitTs(dirpath string, ts int64, opts *Options) (*Binlog, error) {
+	opts = opts.normalize()
+
+	offset, err := searchCommitTs(dirpath, ts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(dirpath, &offset, opts)
+}
+
+// searchCommitTs returns the offset of the first entry whose CommitTs >= ts
+func searchCommitTs(dirpath string, ts int64, opts *Options) (binlogscheme.BinlogOffset, error) {
+	names, err := readBinlogNames(dirpath, opts.Logger)
+	if err != nil {
+		return binlogscheme.BinlogOffset{}, err
+	}
+
+	var end binlogscheme.BinlogOffset
+	for i, name := range names {
+		index, err := parseBinlogName(name)
+		if err != nil {
+			return binlogscheme.BinlogOffset{}, err
+		}
+
+		tail := i == len(names)-1
+		meta, err := loadSegmentMeta(dirpath, index, tail, opts)
+		if err != nil {
+			return binlogscheme.BinlogOffset{}, err
+		}
+
+		end = binlogscheme.BinlogOffset{Index: index, Offset: meta.Size}
+		if meta.Entries == 0 || meta.MaxCommitTs < ts {
+			continue
+		}
+
+		idx, err := loadSegmentIndex(dirpath, index, meta.Size, tail, opts)
+		if err != nil {
+			return binlogscheme.BinlogOffset{}, err
+		}
+
+		from := binlogscheme.BinlogOffset{Index: index, Offset: searchIndexEntry(idx, ts)}
+		offset, found, err := scanCommitTs(dirpath, from, ts, tail, opts)
+		if err != nil {
+			return binlogscheme.BinlogOffset{}, err
+		}
+		if found {
+			return offset, nil
+		}
+	}
+
+	return end, nil
+}
+
+// scanCommitTs decodes the binlog file from the offset for
+// the first entry whose CommitTs >= ts
+func scanCommitTs(dirpath string, from binlogscheme.BinlogOffset, ts int64, tail bool, opts *Options) (binlogscheme.BinlogOffset, bool, error) {
+	f, err := os.Open(path.Join(dirpath, fileName(from.Index)))
+	if err != nil {
+		return binlogscheme.BinlogOffset{}, false, err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(from.Offset, os.SEEK_SET); err != nil {
+		return binlogscheme.BinlogOffset{}, false, err
+	}
+
+	offset := from
+	decoder := newDecoder(&offset, opts, f)
+	ent := &binlogscheme.Entry{}
+	for {
+		err = decoder.decode(ent)
+		if err == io.EOF || (tail && isTornOrEnd(err)) {
+			return binlogscheme.BinlogOffset{}, false, nil
+		}
+		if err != nil {
+			return binlogscheme.BinlogOffset{}, false, err
+		}
+
+		if ent.CommitTs >= ts {
+			return ent.Offset, true, nil
+		}
+	}
+}
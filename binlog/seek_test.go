@@ -0,0 +1,106 @@
+package binlog
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// evenTestEntry is testEntry(i) of CommitTs 2*i, which leaves
+// the odd CommitTs between the entries to seek to
+func evenTestEntry(i int) binlogscheme.Entry {
+	ent := testEntry(i)
+	ent.CommitTs = int64(2 * i)
+	return ent
+}
+
+// checkSeek opens dir at ts and checks the first entry read is want,
+// or that there is none if want is -1
+func checkSeek(t *testing.T, dir string, opts *Options, ts, want int64) {
+	t.Helper()
+	r, err := OpenAtCommitTs(dir, ts, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ents, _ := r.Read(1)
+	if want < 0 {
+		if len(ents) != 0 {
+			t.Fatalf("read CommitTs %d at %d, want nothing", ents[0].CommitTs, ts)
+		}
+		return
+	}
+	if len(ents) != 1 || ents[0].CommitTs != want {
+		t.Fatalf("read %+v at %d, want CommitTs %d", ents, ts, want)
+	}
+}
+
+func TestOpenAtCommitTs(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	b, _ := createTestBinlog(t, dir, opts, 200, evenTestEntry)
+	b.Close()
+
+	for ts, want := range map[int64]int64{0: 0, 1: 2, 77: 78, 150: 150, 398: 398, 1000: -1} {
+		checkSeek(t, dir, opts, ts, want)
+	}
+
+	// a missing meta file is rebuilt from its binlog file
+	if err := os.Remove(path.Join(dir, metaName(1))); err != nil {
+		t.Fatal(err)
+	}
+	checkSeek(t, dir, opts, 77, 78)
+	checkRestored(t, dir, metaName(1), opts)
+}
+
+// checkRestored checks that the missing sidecar file of name
+// is left missing by the readers and written back by OpenForWrite
+func checkRestored(t *testing.T, dir, name string, opts *Options) {
+	t.Helper()
+	p := path.Join(dir, name)
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Fatalf("%s is written by a reader: %v", name, err)
+	}
+
+	b, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	if _, err = os.Stat(p); err != nil {
+		t.Fatalf("%s is not restored by the writer: %v", name, err)
+	}
+}
+
+func TestOpenAtCommitTsAfterEnd(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	b, _ := createTestBinlog(t, dir, opts, 100, evenTestEntry)
+	b.Close()
+
+	// the reader opened at the end gets the entries written later
+	r, err := OpenAtCommitTs(dir, 500, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	w, err := OpenForWrite(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]binlogscheme.Entry{{CommitTs: 500, Payload: []byte("late")}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	ents, _ := r.Read(10)
+	if len(ents) != 1 || ents[0].CommitTs != 500 {
+		t.Fatalf("read %+v after the end", ents)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// LocalStorage keeps the objects as files of a directory,
+// which may be a mounted network file system
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, fileutil.PrivateDirMode); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{dir: dir}, nil
+}
+
+// Put writes the object to a temporary file and renames it, so it's atomic
+func (s *LocalStorage) Put(name string, r io.ReadSeeker) error {
+	p := path.Join(s.dir, name)
+	f, err := os.OpenFile(p+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileutil.PrivateFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = fileutil.Fsync(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+func (s *LocalStorage) Get(name string) (io.ReadCloser, error) {
+	f, err := os.Open(path.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
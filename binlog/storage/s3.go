@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Options are the settings of an S3 compatible object store
+type S3Options struct {
+	Bucket string
+	// Prefix is prepended to the names of the objects
+	Prefix string
+	Region string
+	// Endpoint is set for the S3 compatible stores other than AWS
+	Endpoint string
+	// ForcePathStyle addresses the bucket in the path instead of the
+	// host name, which most of the S3 compatible stores need
+	ForcePathStyle bool
+	// AccessKey and SecretKey are taken from the environment if not set
+	AccessKey string
+	SecretKey string
+}
+
+// S3Storage keeps the objects in an S3 bucket
+type S3Storage struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	cfg := aws.NewConfig().WithS3ForcePathStyle(opts.ForcePathStyle)
+	if opts.Region != "" {
+		cfg = cfg.WithRegion(opts.Region)
+	}
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint)
+	}
+	if opts.AccessKey != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(opts.AccessKey, opts.SecretKey, ""))
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Storage{
+		client: s3.New(sess),
+		bucket: opts.Bucket,
+		prefix: opts.Prefix,
+	}, nil
+}
+
+func (s *S3Storage) key(name string) *string {
+	return aws.String(path.Join(s.prefix, name))
+}
+
+func (s *S3Storage) Put(name string, r io.ReadSeeker) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(name),
+		Body:   r,
+	})
+	return err
+}
+
+func (s *S3Storage) Get(name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(name),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+
+	return out.Body, nil
+}
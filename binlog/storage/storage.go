@@ -0,0 +1,18 @@
+// Package storage is where the binlog files are archived to
+// before they are purged from the local disk
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotExist = errors.New("storage: object does not exist")
+
+// Storage is a flat store of named objects. a Put object shows up as a
+// whole or not at all, and replaces the object of the same name
+type Storage interface {
+	Put(name string, r io.ReadSeeker) error
+	// Get returns ErrNotExist if there is no such object
+	Get(name string) (io.ReadCloser, error)
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 serves the PUT and GET of objects with path style addressing
+func fakeS3(t *testing.T) *httptest.Server {
+	var (
+		mu   sync.Mutex
+		objs = make(map[string][]byte)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			objs[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := objs[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>no such key</Message></Error>`)
+				return
+			}
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testStorage(t *testing.T, st Storage) {
+	if _, err := st.Get("missing"); err != ErrNotExist {
+		t.Fatalf("get a missing object: %v", err)
+	}
+
+	for _, data := range []string{"first version", "second version"} {
+		if err := st.Put("object", strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := st.Get("object")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != data {
+			t.Fatalf("got %q, %v, want %q", got, err, data)
+		}
+	}
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	if err := st.Put("large", bytes.NewReader(large)); err != nil {
+		t.Fatal(err)
+	}
+	r, err := st.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("got %d bytes, %v, want %d", len(got), err, len(large))
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir := path.Join(t.TempDir(), "archive")
+	st, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, st)
+
+	// nothing is left behind by the atomic puts
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("%d files in the storage, want 2", len(files))
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	srv := fakeS3(t)
+	st, err := NewS3Storage(S3Options{
+		Bucket:         "bucket",
+		Prefix:         "pump1",
+		Region:         "us-east-1",
+		Endpoint:       srv.URL,
+		ForcePathStyle: true,
+		AccessKey:      "access",
+		SecretKey:      "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, st)
+
+	// the objects are kept under the prefix
+	other, err := NewS3Storage(S3Options{
+		Bucket:         "bucket",
+		Prefix:         "pump2",
+		Region:         "us-east-1",
+		Endpoint:       srv.URL,
+		ForcePathStyle: true,
+		AccessKey:      "access",
+		SecretKey:      "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.Get("object"); err != ErrNotExist {
+		t.Fatalf("get the object of another prefix: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package binlog
+
+import (
+	"fmt"
+	"time"
+)
+
+type SyncMode int
+
+const (
+	// SyncAlways fsyncs after every Write
+	SyncAlways SyncMode = iota
+	// SyncEveryN fsyncs once every SyncPolicy.Entries entries
+	SyncEveryN
+	// SyncInterval fsyncs every SyncPolicy.Interval from a background goroutine
+	SyncInterval
+	// SyncNever leaves it to the OS to write back the data
+	SyncNever
+)
+
+// SyncPolicy decides when the written entries are fsynced to disk,
+// entries are always flushed to the OS at the end of Write whatever the
+// policy is, and a segment is always synced before a new one is cut.
+// Create and OpenForWrite fail with a policy which can't be followed
+type SyncPolicy struct {
+	Mode     SyncMode
+	Entries  int
+	Interval time.Duration
+}
+
+var DefaultSyncPolicy = SyncPolicy{Mode: SyncAlways}
+
+func (p SyncPolicy) String() string {
+	switch p.Mode {
+	case SyncAlways:
+		return "always"
+	case SyncEveryN:
+		return fmt.Sprintf("every %d entries", p.Entries)
+	case SyncInterval:
+		return fmt.Sprintf("every %v", p.Interval)
+	case SyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("unknown sync mode %d", p.Mode)
+	}
+}
+
+// validate returns an error if the policy can't be followed, SyncEveryN
+// needs positive Entries and SyncInterval needs a positive Interval
+func (p SyncPolicy) validate() error {
+	switch p.Mode {
+	case SyncAlways, SyncNever:
+		return nil
+	case SyncEveryN:
+		if p.Entries > 0 {
+			return nil
+		}
+	case SyncInterval:
+		if p.Interval > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("binlog: bad sync policy %+v", p)
+}
+
+// syncByPolicy is called at the end of every Write
+func (b *Binlog) syncByPolicy() error {
+	switch b.opts.SyncPolicy.Mode {
+	case SyncAlways:
+		return b.sync()
+	case SyncEveryN:
+		if b.unsynced >= b.opts.SyncPolicy.Entries {
+			return b.sync()
+		}
+	}
+
+	return b.encoder.flush()
+}
+
+// Sync flushes the written entries and fsyncs the tail file
+func (b *Binlog) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	if b.poisoned {
+		return ErrPoisoned
+	}
+	if b.tail() == nil {
+		return nil
+	}
+
+	return b.sync()
+}
+
+func (b *Binlog) startSyncLoop() {
+	policy := b.opts.SyncPolicy
+	if policy.Mode != SyncInterval {
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := b.Sync(); err != nil {
+					b.opts.Logger.Errorf("failed to sync binlog %s: %v", b.dir, err)
+				}
+			case <-b.stopc:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,102 @@
+package binlog
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// unsynced returns the number of entries written and not synced yet
+func unsynced(b *Binlog) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unsynced
+}
+
+func syncTestBinlog(t *testing.T, policy SyncPolicy) *Binlog {
+	opts := DefaultOptions()
+	opts.SyncPolicy = policy
+	b, err := Create(path.Join(t.TempDir(), "binlog"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestSyncPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy SyncPolicy
+		// the entries left unsynced after every write
+		unsynced []int
+	}{
+		{SyncPolicy{Mode: SyncAlways}, []int{0, 0, 0, 0}},
+		{SyncPolicy{Mode: SyncEveryN, Entries: 3}, []int{1, 2, 0, 1}},
+		{SyncPolicy{Mode: SyncNever}, []int{1, 2, 3, 4}},
+	} {
+		b := syncTestBinlog(t, tc.policy)
+		for i, want := range tc.unsynced {
+			if err := b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+				t.Fatal(err)
+			}
+			if got := unsynced(b); got != want {
+				t.Fatalf("sync %v: %d entries unsynced after write %d, want %d", tc.policy, got, i, want)
+			}
+		}
+
+		if err := b.Sync(); err != nil || unsynced(b) != 0 {
+			t.Fatalf("sync %v: %d entries unsynced after Sync, %v", tc.policy, unsynced(b), err)
+		}
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	b := syncTestBinlog(t, SyncPolicy{Mode: SyncInterval, Interval: 10 * time.Millisecond})
+	if err := b.Write([]binlogscheme.Entry{testEntry(0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; unsynced(b) != 0; i++ {
+		if i == 100 {
+			t.Fatal("the written entry is never synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncPolicyString(t *testing.T) {
+	for policy, want := range map[SyncPolicy]string{
+		{Mode: SyncAlways}:                          "always",
+		{Mode: SyncEveryN, Entries: 10}:             "every 10 entries",
+		{Mode: SyncInterval, Interval: time.Second}: "every 1s",
+		{Mode: SyncNever}:                           "never",
+	} {
+		if policy.String() != want {
+			t.Fatalf("policy %q, want %q", policy.String(), want)
+		}
+	}
+}
+
+func TestBadSyncPolicy(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	writeTestBinlog(t, dir, nil, 1)
+
+	for _, policy := range []SyncPolicy{
+		{Mode: SyncEveryN},
+		{Mode: SyncEveryN, Entries: -1},
+		{Mode: SyncInterval},
+		{Mode: SyncInterval, Interval: -time.Second},
+		{Mode: SyncNever + 1},
+	} {
+		opts := DefaultOptions()
+		opts.SyncPolicy = policy
+		if _, err := Create(path.Join(t.TempDir(), "binlog"), opts); err == nil {
+			t.Fatalf("create with sync policy %v", policy)
+		}
+		if _, err := OpenForWrite(dir, opts); err == nil {
+			t.Fatalf("open for write with sync policy %v", policy)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/ngaut/log"
 	"github.com/pingcap/tidb-binlog/util/fileutil"
 )
 
@@ -13,7 +12,7 @@ var (
 	badBinlogName = errors.New("bad file name")
 )
 
-//check the dir is already used
+// check the dir is already used
 func Exist(dirpath string) bool {
 	names, err := fileutil.ReadDir(dirpath)
 	if err != nil {
@@ -23,12 +22,12 @@ func Exist(dirpath string) bool {
 	return len(names) != 0
 }
 
-func searchIndex(names []string, index uint64) (int, bool) {
-	if i := len(names) - 1; i >= 0; i-- {
+func searchIndex(names []string, index uint64, logger Logger) (int, bool) {
+	for i := len(names) - 1; i >= 0; i-- {
 		name := names[i]
-		curIndex, err := parseFileName(name)
+		curIndex, err := parseBinlogName(name)
 		if err != nil {
-			log.Errorf("parse correct name should never fail: %v", err)
+			logger.Errorf("parse correct name should never fail: %v", err)
 		}
 
 		if index >= curIndex {
@@ -39,13 +38,13 @@ func searchIndex(names []string, index uint64) (int, bool) {
 	return -1, false
 }
 
-func readBinlogNames(dirpath string) ([]string, error) {
+func readBinlogNames(dirpath string, logger Logger) ([]string, error) {
 	names, err := fileutil.ReadDir(dirpath)
 	if err != nil {
 		return nil, err
 	}
 
-	fnames := checkBinlogNames(names)
+	fnames := checkBinlogNames(names, logger)
 	if len(fnames) == 0 {
 		return nil, ErrFileNotFound
 	}
@@ -53,12 +52,12 @@ func readBinlogNames(dirpath string) ([]string, error) {
 	return fnames, nil
 }
 
-func checkBinlogNames(names []string) []string {
+func checkBinlogNames(names []string, logger Logger) []string {
 	fnames := make([]string, 0)
 	for _, name := range names {
-		if  _, err := parseBinlogName(name); err != nil {
-			if !strings.HasSuffix(name, ".tmp") {
-				log.Warningf("ignored file %v in wal", name)
+		if _, err := parseBinlogName(name); err != nil {
+			if !strings.HasSuffix(name, ".tmp") && !isSidecarName(name) {
+				logger.Warningf("ignored file %v in wal", name)
 			}
 			continue
 		}
@@ -77,6 +76,11 @@ func parseBinlogName(str string) (index uint64, err error) {
 	return
 }
 
+// isSidecarName returns whether the file is kept beside a binlog file
+func isSidecarName(name string) bool {
+	return strings.HasSuffix(name, ".meta") || strings.HasSuffix(name, ".idx")
+}
+
 // the file name format is like 0000000000000001.bl
 func fileName(index uint64) string {
 	return fmt.Sprintf("%016d.bl", index)
@@ -0,0 +1,214 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// SegmentReport is what Verify finds in one binlog file
+type SegmentReport struct {
+	Name    string
+	Index   uint64
+	Entries int64
+	// Bytes is where the valid data of the binlog file ends
+	Bytes         int64
+	FirstCommitTs int64
+	LastCommitTs  int64
+
+	// TsRegressions counts the entries whose CommitTs is below the one
+	// of the entry before them, FirstRegression is the first of them
+	TsRegressions   int64
+	FirstRegression *binlogscheme.BinlogOffset
+
+	// Corruption is the offset of the first frame which can't be decoded,
+	// the rest of the file is not checked. Err tells why
+	Corruption *binlogscheme.BinlogOffset
+	Err        error
+
+	// TornBytes are the bytes of a half written entry at the end of the
+	// tail binlog file, they are cut off when it's opened for writing.
+	// a bad frame of the tail followed by valid ones is a Corruption
+	TornBytes int64
+}
+
+// VerifyReport is the result of Verify
+type VerifyReport struct {
+	Dir      string
+	Segments []*SegmentReport
+	// Missing are the indexes missing between the first and the last binlog file
+	Missing []uint64
+}
+
+// OK returns whether no corruption nor missing binlog file is found, the
+// CommitTs regressions and the torn tail are not taken as errors
+func (r *VerifyReport) OK() bool {
+	if len(r.Missing) > 0 {
+		return false
+	}
+	for _, s := range r.Segments {
+		if s.Corruption != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Entries returns the number of the valid entries of all the binlog files
+func (r *VerifyReport) Entries() int64 {
+	var n int64
+	for _, s := range r.Segments {
+		n += s.Entries
+	}
+	return n
+}
+
+// Verify walks through every binlog file of the directory in order and
+// decodes every frame of them. it checks the continuity of the file
+// indexes, the frames and the order of CommitTs, opts may be nil and
+// needs the KeyProvider of the encrypted files.
+// the returned error is only about failing to read the directory,
+// what's wrong with the binlog files is in the report
+func Verify(dirpath string, opts *Options) (*VerifyReport, error) {
+	opts = opts.normalize()
+
+	names, err := readBinlogNames(dirpath, opts.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{Dir: dirpath}
+	var (
+		lastTs  int64
+		hasLast bool
+	)
+	for i, name := range names {
+		index, err := parseBinlogName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			prev := report.Segments[i-1].Index
+			for missing := prev + 1; missing < index; missing++ {
+				report.Missing = append(report.Missing, missing)
+			}
+		}
+
+		s, err := verifySegment(dirpath, index, i == len(names)-1, opts)
+		if err != nil {
+			return nil, err
+		}
+		s.Name = name
+
+		// the order of CommitTs is checked across the files
+		if s.Entries > 0 {
+			if hasLast && s.FirstCommitTs < lastTs {
+				s.TsRegressions++
+				if s.FirstRegression == nil {
+					s.FirstRegression = &binlogscheme.BinlogOffset{Index: index, Offset: segmentHeaderBytes}
+				}
+			}
+			lastTs, hasLast = s.LastCommitTs, true
+		}
+
+		report.Segments = append(report.Segments, s)
+	}
+
+	return report, nil
+}
+
+func verifySegment(dirpath string, index uint64, tail bool, opts *Options) (*SegmentReport, error) {
+	f, err := os.Open(path.Join(dirpath, fileName(index)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &SegmentReport{Index: index}
+	if _, err = checkSegmentHeader(f, index); err != nil {
+		if _, ok := err.(*SegmentHeaderError); !ok {
+			return nil, err
+		}
+		s.Corruption = &binlogscheme.BinlogOffset{Index: index}
+		s.Err = err
+		return s, nil
+	}
+
+	if _, err = f.Seek(segmentHeaderBytes, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	offset := &binlogscheme.BinlogOffset{Index: index, Offset: segmentHeaderBytes}
+	decoder := newDecoder(offset, opts, f)
+	ent := &binlogscheme.Entry{}
+	for {
+		start := offset.Offset
+		err = decoder.decode(ent)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the tail is checked for a torn frame below
+			if tail && isTornOrEnd(err) {
+				break
+			}
+
+			s.Bytes = start
+			s.Corruption = &binlogscheme.BinlogOffset{Index: index, Offset: start}
+			s.Err = err
+			return s, nil
+		}
+
+		if s.Entries == 0 {
+			s.FirstCommitTs = ent.CommitTs
+		} else if ent.CommitTs < s.LastCommitTs {
+			s.TsRegressions++
+			if s.FirstRegression == nil {
+				regression := ent.Offset
+				s.FirstRegression = &regression
+			}
+		}
+		s.LastCommitTs = ent.CommitTs
+		s.Entries++
+	}
+	s.Bytes = offset.Offset
+
+	// the files are preallocated with zeroes, anything else behind
+	// the data end is either torn or corrupted
+	end, err := dataEnd(f, s.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if end == s.Bytes {
+		return s, nil
+	}
+
+	// only the last frame of the tail can be torn, a bad frame
+	// followed by valid ones is corrupted wherever it is
+	bad := binlogscheme.BinlogOffset{Index: index, Offset: s.Bytes}
+	if tail {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		torn, err := tornFrame(f, s.Bytes, end, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		if torn {
+			s.TornBytes = end - s.Bytes
+			return s, nil
+		}
+		s.Corruption = &bad
+		s.Err = frameError(f, bad, opts)
+		return s, nil
+	}
+
+	s.Corruption = &bad
+	s.Err = fmt.Errorf("binlog: %d bytes of garbage behind the data end", end-s.Bytes)
+	return s, nil
+}
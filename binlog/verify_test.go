@@ -0,0 +1,109 @@
+package binlog
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+func verifyTail(t *testing.T, dir string, opts *Options) *SegmentReport {
+	r, err := Verify(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Segments[len(r.Segments)-1]
+}
+
+func TestVerify(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 1024
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = b.Write([]binlogscheme.Entry{testEntry(5)}); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	r, err := Verify(dir, opts)
+	if err != nil || !r.OK() || r.Entries() != 101 {
+		t.Fatalf("report %+v, %v", r, err)
+	}
+	var regressions int64
+	for _, s := range r.Segments {
+		regressions += s.TsRegressions
+	}
+	if regressions != 1 {
+		t.Fatalf("found %d CommitTs regressions, want 1", regressions)
+	}
+
+	writeAt(t, dir, 1, 200, []byte{0xff})
+	if r, err = Verify(dir, opts); err != nil || r.OK() || r.Segments[1].Corruption == nil || r.Segments[1].Corruption.Offset > 200 {
+		t.Fatalf("report of the corrupted file %+v, %v", r.Segments[1], err)
+	}
+
+	if err = os.Remove(path.Join(dir, fileName(2))); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = Verify(dir, opts); err != nil || len(r.Missing) != 1 || r.Missing[0] != 2 {
+		t.Fatalf("missing %v, %v", r.Missing, err)
+	}
+}
+
+func TestVerifyTornTail(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	offsets := writeTestBinlog(t, dir, opts, 10)
+	end := offsets[9]
+	end.Offset += testFrameBytes(9)
+
+	torn := halfFrame(100)
+	writeAt(t, dir, end.Index, end.Offset, torn)
+
+	s := verifyTail(t, dir, opts)
+	if s.Corruption != nil || s.Entries != 10 || s.Bytes != end.Offset || s.TornBytes != int64(len(torn)) {
+		t.Fatalf("report of a torn tail %+v", s)
+	}
+}
+
+func TestVerifyCorruptedLastFrame(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	offsets := writeTestBinlog(t, dir, opts, 10)
+
+	last := offsets[9]
+	flipPayload(t, dir, last, 9)
+
+	ent := testEntry(9)
+	s := verifyTail(t, dir, opts)
+	if s.Corruption != nil || s.Entries != 9 || s.TornBytes != frameHeaderBytes+int64(ent.MarshalSize()) {
+		t.Fatalf("report of a bad last frame %+v", s)
+	}
+}
+
+func TestVerifyTailCorruption(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	offsets := writeTestBinlog(t, dir, opts, 10)
+
+	// valid frames follow the bad one, so it's not torn
+	bad := offsets[5]
+	flipPayload(t, dir, bad, 5)
+
+	s := verifyTail(t, dir, opts)
+	if s.Corruption == nil || *s.Corruption != bad || s.TornBytes != 0 || s.Entries != 5 {
+		t.Fatalf("report of a corrupted tail %+v", s)
+	}
+	if !IsCorruption(s.Err) {
+		t.Fatalf("the corrupted tail fails with %v", s.Err)
+	}
+}
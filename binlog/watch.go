@@ -0,0 +1,289 @@
+package binlog
+
+import (
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+	"golang.org/x/net/context"
+)
+
+// the max number of entries sent in one WatchResponse
+const watchBatchSize = 128
+
+// WatchResponse carries the entries that were newly read by Watch,
+// or the error that stopped the watch
+type WatchResponse struct {
+	Entries []binlogscheme.Entry
+	// Next is where the watch reads the next entry from, a response
+	// without entries is sent at first and when it moves to the next
+	// binlog file
+	Next binlogscheme.BinlogOffset
+	Err  error
+}
+
+// Watch streams the entries from the offset, then blocks for the new ones
+// and follows the newly cut binlog files. it's woken up at once by the
+// writes of this Binlog, and polls every Options.WatchInterval for the
+// writes of the others. the returned channel is closed when ctx is done
+// or after a response with Err is sent. the files from the offset are
+// pinned against GC before Watch returns
+func (b *Binlog) Watch(ctx context.Context, offset binlogscheme.BinlogOffset) <-chan WatchResponse {
+	wc := make(chan WatchResponse)
+	go b.watch(ctx, newTailer(b.dir, offset, b.opts), wc)
+	return wc
+}
+
+func (b *Binlog) watch(ctx context.Context, t *tailer, wc chan<- WatchResponse) {
+	defer close(wc)
+	defer t.release()
+
+	ticker := time.NewTicker(b.opts.WatchInterval)
+	defer ticker.Stop()
+
+	var sent binlogscheme.BinlogOffset
+	for first := true; ; first = false {
+		// take the notify channel before reading, so no write is missed
+		notifyc := b.notifyc()
+
+		ents, err := t.next(watchBatchSize)
+		if next := t.position(); first || len(ents) > 0 || next != sent {
+			select {
+			case wc <- WatchResponse{Entries: ents, Next: next}:
+			case <-ctx.Done():
+				return
+			}
+			sent = next
+		}
+
+		if err != nil {
+			select {
+			case wc <- WatchResponse{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		if len(ents) > 0 {
+			continue
+		}
+
+		select {
+		case <-notifyc:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyc returns a channel which is closed by the next write
+func (b *Binlog) notifyc() <-chan struct{} {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	if b.notify == nil {
+		b.notify = make(chan struct{})
+	}
+	return b.notify
+}
+
+// broadcast wakes up the watchers after entries are written
+func (b *Binlog) broadcast() {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	if b.notify != nil {
+		close(b.notify)
+		b.notify = nil
+	}
+}
+
+// tailer reads a binlog directory which may be still written,
+// it only moves forward over complete entries
+type tailer struct {
+	dir    string
+	opts   *Options
+	offset binlogscheme.BinlogOffset
+
+	f       segmentFile
+	decoder *decoder
+	pin     *pin
+}
+
+func newTailer(dir string, offset binlogscheme.BinlogOffset, opts *Options) *tailer {
+	return &tailer{
+		dir:    dir,
+		opts:   opts,
+		offset: offset,
+		pin:    pinFiles(dir, offset.Index),
+	}
+}
+
+// position returns the offset of the next entry, the start
+// of a binlog file is taken as the end of its header
+func (t *tailer) position() binlogscheme.BinlogOffset {
+	pos := t.offset
+	if pos.Offset < segmentHeaderBytes {
+		pos.Offset = segmentHeaderBytes
+	}
+	return pos
+}
+
+// next returns at most max complete entries behind the offset,
+// it returns no entry and nil error when there is nothing new
+func (t *tailer) next(max int) ([]binlogscheme.Entry, error) {
+	var ents []binlogscheme.Entry
+	for len(ents) < max {
+		var ent binlogscheme.Entry
+		ok, err := t.decode(&ent, nil)
+		if ok {
+			ents = append(ents, ent)
+		}
+		if err != nil || !ok {
+			return ents, err
+		}
+	}
+
+	return ents, nil
+}
+
+// nextInto is next decoding into ents and the arena
+func (t *tailer) nextInto(ents []binlogscheme.Entry, a *Arena) (int, error) {
+	for i := range ents {
+		ok, err := t.decode(&ents[i], a)
+		if !ok || err != nil {
+			return i, err
+		}
+	}
+
+	return len(ents), nil
+}
+
+// decode decodes the next complete entry into ent, it moves to the next
+// file when the current one is sealed. it returns false with nil error
+// if there is nothing new
+func (t *tailer) decode(ent *binlogscheme.Entry, a *Arena) (bool, error) {
+	for {
+		err := t.decodeOne(ent, a)
+		if err == nil {
+			return true, nil
+		}
+
+		if !isTornOrEnd(err) {
+			return false, err
+		}
+
+		// a bad frame is waited for until it's completed,
+		// unless valid frames are written behind it already
+		torn, terr := t.torn()
+		if terr != nil {
+			return false, terr
+		}
+		if !torn {
+			return false, frameError(t.f, *t.decoder.offset, t.opts)
+		}
+
+		// the next try has to decode from the offset again
+		t.close()
+
+		sealed := fileutil.Exist(path.Join(t.dir, fileName(t.offset.Index+1)))
+		if !sealed {
+			return false, nil
+		}
+
+		// the file was completed before the next one was cut,
+		// decode once more to tell its end from a corruption
+		err = t.decodeOne(ent, a)
+		switch {
+		case err == nil:
+			return true, nil
+		case err == io.EOF:
+			t.close()
+			t.offset = binlogscheme.BinlogOffset{Index: t.offset.Index + 1, Offset: 0}
+			t.pin.move(t.offset.Index)
+		default:
+			return false, err
+		}
+	}
+}
+
+func (t *tailer) decodeOne(ent *binlogscheme.Entry, a *Arena) error {
+	if t.decoder == nil {
+		if err := t.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := t.decoder.decodeInto(ent, a); err != nil {
+		return err
+	}
+
+	t.offset = *t.decoder.offset
+	return nil
+}
+
+// torn returns whether the frame failing to decode at the offset of the
+// decoder is torn or still being written. only the data right behind it is
+// looked at, the frames of a writer are appended in order, so a bad frame
+// followed by zeroes is taken as torn until the file is sealed
+func (t *tailer) torn() (bool, error) {
+	if t.decoder == nil || t.decoder.offset.Offset < segmentHeaderBytes {
+		return true, nil
+	}
+
+	size, ok := fileSize(t.f)
+	if !ok {
+		return true, nil
+	}
+
+	offset := t.decoder.offset.Offset
+	end, err := zeroWord(t.f, nextFrame(t.f, offset), size)
+	if err != nil {
+		return false, err
+	}
+	return tornFrame(t.f, offset, end, size)
+}
+
+func (t *tailer) open() error {
+	// the file is complete once the next one is cut
+	sealed := fileutil.Exist(path.Join(t.dir, fileName(t.offset.Index+1)))
+	f, err := openSegmentFile(t.dir, t.offset.Index, sealed, t.opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Seek(t.offset.Offset, os.SEEK_SET); err != nil {
+		f.Close()
+		return err
+	}
+
+	pos := t.offset
+	t.f = f
+	t.decoder = newDecoder(&pos, t.opts, f)
+	return nil
+}
+
+func (t *tailer) close() {
+	if t.f != nil {
+		t.f.Close()
+	}
+	t.f = nil
+	t.decoder = nil
+}
+
+// release closes the tailer and unpins its files
+func (t *tailer) release() {
+	t.close()
+	t.pin.release()
+}
+
+// isTornOrEnd returns whether err means that there is no complete entry
+// behind the offset, which is expected at the end of the tail file
+func isTornOrEnd(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF ||
+		err == binlogscheme.ErrorFormat || IsCorruption(err)
+}
@@ -0,0 +1,196 @@
+package binlog
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"golang.org/x/net/context"
+)
+
+func TestWatch(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := DefaultOptions()
+	opts.SegmentSize = 2048
+	opts.GroupCommitMaxBatch = 16
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wc := b.Watch(ctx, binlogscheme.BinlogOffset{})
+
+	const n = 300
+	errc := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for i := 0; i < n; {
+		select {
+		case resp := <-wc:
+			if resp.Err != nil {
+				t.Fatal(resp.Err)
+			}
+			for _, ent := range resp.Entries {
+				if ent.CommitTs != testEntry(i).CommitTs {
+					t.Fatalf("watch entry %d of CommitTs %d", i, ent.CommitTs)
+				}
+				i++
+			}
+		case err := <-errc:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("watch %d entries, want %d", i, n)
+		}
+	}
+}
+
+// watchAll collects the entries of the watch until it fails or
+// nothing new comes within wait
+func watchAll(t *testing.T, b *Binlog, wait time.Duration) ([]binlogscheme.Entry, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wc := b.Watch(ctx, binlogscheme.BinlogOffset{})
+
+	var ents []binlogscheme.Entry
+	for {
+		select {
+		case resp := <-wc:
+			ents = append(ents, resp.Entries...)
+			if resp.Err != nil {
+				return ents, resp.Err
+			}
+		case <-time.After(wait):
+			return ents, nil
+		}
+	}
+}
+
+func TestWatchTornFrame(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	opts.WatchInterval = 10 * time.Millisecond
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for i := 0; i < 10; i++ {
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a frame being written is waited for
+	end := b.LastOffset()
+	writeAt(t, dir, end.Index, end.Offset, halfFrame(100))
+
+	ents, err := watchAll(t, b, 100*time.Millisecond)
+	if err != nil || len(ents) != 10 {
+		t.Fatalf("watch %d entries, %v", len(ents), err)
+	}
+}
+
+func TestWatchCorruption(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	opts := repairTestOptions(true)
+	opts.WatchInterval = 10 * time.Millisecond
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	var offsets []binlogscheme.BinlogOffset
+	for i := 0; i < 10; i++ {
+		offsets = append(offsets, b.LastOffset())
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a bad frame followed by valid ones is not waited for
+	flipPayload(t, dir, offsets[5], 5)
+
+	ents, err := watchAll(t, b, 5*time.Second)
+	if len(ents) != 5 || !IsCorruption(err) {
+		t.Fatalf("watch %d entries, %v", len(ents), err)
+	}
+	if ce := err.(*CorruptionError); ce.Offset != offsets[5] {
+		t.Fatalf("corruption at %+v, want %+v", ce.Offset, offsets[5])
+	}
+}
+
+func TestWatchPinsFiles(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, _ := createTestBinlog(t, dir, gcTestOptions(), 200, testEntry)
+	start := binlogscheme.BinlogOffset{Index: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wc := b.Watch(ctx, start)
+
+	// the files from the offset are pinned once Watch returns
+	if _, err := b.GC(GCPolicy{SafeCommitTs: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if names := binlogNames(t, dir); names[0] != fileName(2) {
+		t.Fatalf("the oldest binlog file is %s, want %s", names[0], fileName(2))
+	}
+
+	resp := <-wc
+	if resp.Err != nil || len(resp.Entries) == 0 || resp.Entries[0].Offset.Index != 2 {
+		t.Fatalf("watch %d entries from %+v, %v", len(resp.Entries), start, resp.Err)
+	}
+}
+
+func TestWatchNext(t *testing.T) {
+	dir := path.Join(t.TempDir(), "binlog")
+	b, err := Create(dir, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wc := b.Watch(ctx, binlogscheme.BinlogOffset{})
+
+	// the first response tells where the watch is, with no entry
+	if resp := <-wc; len(resp.Entries) != 0 || resp.Next != b.LastOffset() {
+		t.Fatalf("watch at %+v with %d entries, want %+v", resp.Next, len(resp.Entries), b.LastOffset())
+	}
+
+	for i := 0; i < 3; i++ {
+		if err = b.Write([]binlogscheme.Entry{testEntry(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if resp := <-wc; len(resp.Entries) != 1 || resp.Next != b.LastOffset() {
+			t.Fatalf("watch at %+v with %d entries, want %+v", resp.Next, len(resp.Entries), b.LastOffset())
+		}
+	}
+
+	// moving to the next binlog file is sent without entries
+	b.mu.Lock()
+	err = b.cut()
+	b.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.broadcast()
+	if resp := <-wc; len(resp.Entries) != 0 || resp.Next != b.LastOffset() {
+		t.Fatalf("watch at %+v with %d entries, want %+v", resp.Next, len(resp.Entries), b.LastOffset())
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+const dumpBatchSize = 128
+
+type dumpFilter struct {
+	fromIndex uint64
+	toIndex   uint64
+	startTs   int64
+	endTs     int64
+}
+
+func (f *dumpFilter) match(ent *binlogscheme.Entry) bool {
+	return ent.Offset.Index >= f.fromIndex && ent.CommitTs >= f.startTs && ent.CommitTs <= f.endTs
+}
+
+// dumpEntry is how an entry is printed with -json
+type dumpEntry struct {
+	Index    uint64 `json:"index"`
+	Offset   int64  `json:"offset"`
+	CommitTs int64  `json:"commit-ts"`
+	StartTs  int64  `json:"start-ts"`
+	Size     int64  `json:"size"`
+	Payload  string `json:"payload,omitempty"`
+}
+
+// runDump prints the entries of a binlog directory or a single binlog file
+func runDump(args []string) int {
+	fs := newFlagSet("dump")
+	dir := fs.String("dir", "", "the binlog directory")
+	file := fs.String("file", "", "dump only this binlog file, instead of -dir")
+	keyFile := fs.String("key-file", "", "the key file of the encrypted binlog files")
+	fromIndex := fs.Uint64("from-index", 0, "the first binlog file index to dump")
+	toIndex := fs.Uint64("to-index", math.MaxUint64, "the last binlog file index to dump")
+	startTs := fs.Int64("start-ts", 0, "dump the entries whose CommitTs >= start-ts")
+	endTs := fs.Int64("end-ts", math.MaxInt64, "dump the entries whose CommitTs <= end-ts")
+	limit := fs.Int("limit", 0, "the max number of entries to dump, 0 means no limit")
+	payload := fs.String("payload", "hex", "how to print the payload: hex, base64 or none")
+	asJSON := fs.Bool("json", false, "print one JSON object per entry")
+	fs.Parse(args)
+
+	filter := &dumpFilter{fromIndex: *fromIndex, toIndex: *toIndex, startTs: *startTs, endTs: *endTs}
+	if *file != "" {
+		var index uint64
+		if _, err := fmt.Sscanf(filepath.Base(*file), "%016d.bl", &index); err != nil {
+			fmt.Fprintf(os.Stderr, "binlogctl dump: %s is not a binlog file\n", *file)
+			return 2
+		}
+		*dir = filepath.Dir(*file)
+		filter.fromIndex, filter.toIndex = index, index
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "binlogctl dump: -dir or -file is required")
+		return 2
+	}
+
+	var encode func([]byte) string
+	switch *payload {
+	case "hex":
+		encode = hex.EncodeToString
+	case "base64":
+		encode = base64.StdEncoding.EncodeToString
+	case "none":
+	default:
+		fmt.Fprintf(os.Stderr, "binlogctl dump: unknown payload format %q\n", *payload)
+		return 2
+	}
+
+	opts, err := binlogOptions(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "binlogctl dump: %v\n", err)
+		return 2
+	}
+
+	r, err := openDump(*dir, filter, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "binlogctl dump: %v\n", err)
+		return 2
+	}
+	defer r.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	n := 0
+	for {
+		ents, err := r.Read(dumpBatchSize)
+		for i := range ents {
+			ent := &ents[i]
+			if ent.Offset.Index > filter.toIndex {
+				return 0
+			}
+			if !filter.match(ent) {
+				continue
+			}
+
+			de := dumpEntry{
+				Index:    ent.Offset.Index,
+				Offset:   ent.Offset.Offset,
+				CommitTs: ent.CommitTs,
+				StartTs:  ent.StartTs,
+				Size:     ent.Size,
+			}
+			if encode != nil {
+				de.Payload = encode(ent.Payload)
+			}
+
+			if *asJSON {
+				enc.Encode(de)
+			} else {
+				fmt.Printf("index %d offset %d commit-ts %d start-ts %d size %d", de.Index, de.Offset, de.CommitTs, de.StartTs, de.Size)
+				if encode != nil {
+					fmt.Printf(" payload %s", de.Payload)
+				}
+				fmt.Println()
+			}
+
+			n++
+			if *limit > 0 && n >= *limit {
+				return 0
+			}
+		}
+
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "binlogctl dump: failed to read after %d entries: %v\n", n, err)
+			return 1
+		}
+	}
+}
+
+// openDump opens the directory at the first binlog file to dump, or at
+// the first entry of start-ts found by the sparse index if there's no
+// from-index
+func openDump(dir string, filter *dumpFilter, opts *binlog.Options) (*binlog.Binlog, error) {
+	if filter.fromIndex > 0 {
+		r, err := binlog.Open(dir, &binlogscheme.BinlogOffset{Index: filter.fromIndex}, opts)
+		if err != binlog.ErrFileNotFound {
+			return r, err
+		}
+	}
+
+	return binlog.OpenAtCommitTs(dir, filter.startTs, opts)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogtest"
+)
+
+// writeDumpTestBinlog writes 100 entries over several binlog files
+func writeDumpTestBinlog(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "binlog")
+	opts := binlog.DefaultOptions()
+	opts.SegmentSize = 1024
+	binlogtest.Write(t, dir, opts, 100)
+	return dir
+}
+
+// run runs the command with stdout captured
+func run(t *testing.T, cmd func([]string) int, args ...string) (int, string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	outc := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		outc <- buf.String()
+	}()
+
+	code := cmd(args)
+	w.Close()
+	return code, <-outc
+}
+
+func dumpJSON(t *testing.T, args ...string) []dumpEntry {
+	code, out := run(t, runDump, append(args, "-json")...)
+	if code != 0 {
+		t.Fatalf("dump %v exits with %d", args, code)
+	}
+
+	var ents []dumpEntry
+	s := bufio.NewScanner(bytes.NewBufferString(out))
+	for s.Scan() {
+		var de dumpEntry
+		if err := json.Unmarshal(s.Bytes(), &de); err != nil {
+			t.Fatalf("dump %v prints %q: %v", args, s.Text(), err)
+		}
+		ents = append(ents, de)
+	}
+	return ents
+}
+
+func TestDump(t *testing.T) {
+	dir := writeDumpTestBinlog(t)
+
+	ents := dumpJSON(t, "-dir", dir, "-payload", "base64")
+	if len(ents) != 100 {
+		t.Fatalf("dumped %d entries, want 100", len(ents))
+	}
+	for i, de := range ents {
+		if de.CommitTs != int64(i+1) || de.StartTs != int64(i) || de.Payload == "" {
+			t.Fatalf("entry %d is dumped as %+v", i, de)
+		}
+	}
+	last := ents[len(ents)-1]
+	if last.Index == 0 {
+		t.Fatal("the test binlog is not cut")
+	}
+
+	// the filters
+	ents = dumpJSON(t, "-dir", dir, "-start-ts", "20", "-end-ts", "30", "-payload", "none")
+	if len(ents) != 11 || ents[0].CommitTs != 20 || ents[10].CommitTs != 30 || ents[0].Payload != "" {
+		t.Fatalf("dumped %d entries from %+v by CommitTs", len(ents), ents[0])
+	}
+	if ents = dumpJSON(t, "-dir", dir, "-start-ts", "20", "-limit", "5"); len(ents) != 5 || ents[4].CommitTs != 24 {
+		t.Fatalf("dumped %d entries with a limit", len(ents))
+	}
+	ents = dumpJSON(t, "-dir", dir, "-from-index", "1", "-to-index", "1")
+	if len(ents) == 0 || ents[0].Index != 1 || ents[len(ents)-1].Index != 1 {
+		t.Fatalf("dumped %d entries of binlog file 1", len(ents))
+	}
+	file := dumpJSON(t, "-file", filepath.Join(dir, fmt.Sprintf("%016d.bl", last.Index)))
+	if len(file) == 0 || file[0].Index != last.Index || file[len(file)-1].CommitTs != last.CommitTs {
+		t.Fatalf("dumped %d entries of the last binlog file", len(file))
+	}
+
+	code, out := run(t, runDump, "-dir", dir, "-limit", "1")
+	if want := "index 0 offset 32 commit-ts 1 start-ts 0 size 18 payload 7061796c6f6164206f6620656e7472792030\n"; code != 0 || out != want {
+		t.Fatalf("dump prints %q, %d, want %q", out, code, want)
+	}
+	if code, _ = run(t, runDump, "-dir", dir, "-payload", "ascii"); code != 2 {
+		t.Fatalf("dump with a bad payload format exits with %d", code)
+	}
+}
+
+func TestDumpReadOnly(t *testing.T) {
+	dir := writeDumpTestBinlog(t)
+	for _, name := range []string{"0000000000000001.meta", "0000000000000001.idx"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the dump of a copied binlog directory leaves it as it is
+	if ents := dumpJSON(t, "-dir", dir, "-start-ts", "50"); len(ents) != 51 {
+		t.Fatalf("dumped %d entries, want 51", len(ents))
+	}
+	after, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("dump changes the binlog directory from %d files to %d", len(before), len(after))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := writeDumpTestBinlog(t)
+	if code, _ := run(t, runVerify, "-dir", dir); code != 0 {
+		t.Fatalf("verify exits with %d", code)
+	}
+
+	if err := os.Remove(filepath.Join(dir, fmt.Sprintf("%016d.bl", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := run(t, runVerify, "-dir", dir); code != 1 {
+		t.Fatalf("verify of a missing binlog file exits with %d", code)
+	}
+}
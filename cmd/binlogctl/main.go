@@ -0,0 +1,66 @@
+// binlogctl inspects the binlog directory of a pump offline
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"verify", "verify every binlog file of a directory and print a report", runVerify},
+	{"dump", "print the entries of a binlog directory or file", runDump},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: binlogctl <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun 'binlogctl <command> -h' for the flags of a command\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "binlogctl: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+// binlogOptions builds the options to read the binlog files with,
+// keyFile is needed by the encrypted ones
+func binlogOptions(keyFile string) (*binlog.Options, error) {
+	opts := binlog.DefaultOptions()
+	if keyFile == "" {
+		return opts, nil
+	}
+
+	kp, err := binlog.NewFileKeyProvider(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	opts.KeyProvider = kp
+	return opts, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("binlogctl "+name, flag.ExitOnError)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+)
+
+// runVerify prints the report of binlog.Verify, it exits with 1
+// if any binlog file is corrupted or missing
+func runVerify(args []string) int {
+	fs := newFlagSet("verify")
+	dir := fs.String("dir", "", "the binlog directory")
+	keyFile := fs.String("key-file", "", "the key file of the encrypted binlog files")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "binlogctl verify: -dir is required")
+		return 2
+	}
+
+	opts, err := binlogOptions(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "binlogctl verify: %v\n", err)
+		return 2
+	}
+
+	report, err := binlog.Verify(*dir, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "binlogctl verify: %v\n", err)
+		return 2
+	}
+
+	printReport(report)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+func printReport(report *binlog.VerifyReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tENTRIES\tBYTES\tFIRST TS\tLAST TS\tTS REGRESSIONS\tSTATUS")
+	for _, s := range report.Segments {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			s.Name, s.Entries, s.Bytes, s.FirstCommitTs, s.LastCommitTs, s.TsRegressions, segmentStatus(s))
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, index := range report.Missing {
+		fmt.Printf("missing binlog file of index %d\n", index)
+	}
+	for _, s := range report.Segments {
+		if s.Corruption != nil {
+			fmt.Printf("%s: first corruption at offset %d: %v\n", s.Name, s.Corruption.Offset, s.Err)
+		}
+		if s.FirstRegression != nil {
+			fmt.Printf("%s: first CommitTs regression at offset %d\n", s.Name, s.FirstRegression.Offset)
+		}
+		if s.TornBytes > 0 {
+			fmt.Printf("%s: %d torn bytes at the end, they are cut off when the pump restarts\n", s.Name, s.TornBytes)
+		}
+	}
+
+	status := "OK"
+	if !report.OK() {
+		status = "CORRUPTED"
+	}
+	fmt.Printf("%s: %d binlog files, %d entries, %s\n", report.Dir, len(report.Segments), report.Entries(), status)
+}
+
+func segmentStatus(s *binlog.SegmentReport) string {
+	switch {
+	case s.Corruption != nil:
+		return "corrupted"
+	case s.TornBytes > 0:
+		return "torn"
+	default:
+		return "ok"
+	}
+}
@@ -0,0 +1,80 @@
+// pump receives the binlogs of the TiDB servers and serves them to the drainers
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/config"
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/pump"
+	"github.com/pingcap/tidb-binlog/registry"
+	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
+	"go.etcd.io/etcd/client/v3"
+	"golang.org/x/net/context"
+)
+
+func main() {
+	cfg := config.NewConfig()
+	if err := cfg.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		log.Fatalf("failed to parse flags: %v", err)
+	}
+
+	reg, err := newRegistry(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to etcd %s: %v", cfg.EtcdURLs, err)
+	}
+
+	s, err := pump.NewServer(cfg)
+	if err != nil {
+		log.Fatalf("failed to create pump server: %v", err)
+	}
+
+	m, err := machine.NewMachineFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to get the machine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = s.JoinBoard(reg, m.ID()); err != nil {
+		log.Fatalf("failed to join the window board: %v", err)
+	}
+	if err = pump.Register(ctx, reg, m, cfg.Heartbeat); err != nil {
+		log.Fatalf("failed to register machine %s: %v", m.ID(), err)
+	}
+	go s.RunBoard(ctx, reg, m.ID(), cfg.BoardInterval)
+
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		sig := <-sc
+		log.Infof("got signal [%v] to exit", sig)
+		cancel()
+		s.Close()
+	}()
+
+	if err := s.Start(); err != nil {
+		log.Fatalf("pump server stopped: %v", err)
+	}
+	log.Info("pump exit")
+}
+
+func newRegistry(cfg *config.Config) (*registry.EtcdRegistry, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   strings.Split(cfg.EtcdURLs, ","),
+		DialTimeout: cfg.EtcdTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return registry.NewEtcdRegistry(etcd.NewEtcd(client, cfg.EtcdPrefix, cfg.EtcdTimeout, cfg.EtcdURLs), cfg.EtcdTimeout), nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog"
+)
+
+// Config is the configuration of a pump
+type Config struct {
+	*flag.FlagSet
+
+	// ListenAddr is where the gRPC service listens
+	ListenAddr string
+	// DataDir holds the binlog files
+	DataDir string
+	// HostIP and HostName identify the machine, they are
+	// taken from the network interfaces if not set
+	HostIP   string
+	HostName string
+
+	// EtcdURLs are the comma separated endpoints of the etcd
+	// where the machine of the pump is registered
+	EtcdURLs   string
+	EtcdPrefix string
+	// EtcdTimeout bounds the dialing and every request to etcd
+	EtcdTimeout time.Duration
+	// Heartbeat is how often the machine is kept alive in etcd
+	Heartbeat time.Duration
+	// BoardInterval is how often the durable CommitTs of the
+	// pump is published and the window board is advanced
+	BoardInterval time.Duration
+
+	SegmentSize int64
+	// GroupCommit is the max number of entries synced together, 0 disables it
+	GroupCommit int
+	Compression string
+	// KeyFile holds the keys to encrypt the binlog files, see binlog.FileKeyProvider
+	KeyFile string
+	// GCRetention purges the binlog files older than it, 0 disables GC.
+	// it's off by default since a file a slow drainer hasn't pulled is
+	// purged too
+	GCRetention time.Duration
+}
+
+func NewConfig() *Config {
+	cfg := &Config{}
+	cfg.FlagSet = flag.NewFlagSet("pump", flag.ContinueOnError)
+	fs := cfg.FlagSet
+
+	fs.StringVar(&cfg.ListenAddr, "addr", "127.0.0.1:8250", "the address the gRPC service listens on")
+	fs.StringVar(&cfg.DataDir, "data-dir", "data.pump", "the directory of the binlog files")
+	fs.StringVar(&cfg.HostIP, "host-ip", "", "the IP of this machine")
+	fs.StringVar(&cfg.HostName, "host-name", "", "the host name of this machine")
+	fs.StringVar(&cfg.EtcdURLs, "etcd-urls", "http://127.0.0.1:2379", "the comma separated endpoints of etcd")
+	fs.StringVar(&cfg.EtcdPrefix, "etcd-prefix", "/tidb-binlog", "the prefix of the keys in etcd")
+	fs.DurationVar(&cfg.EtcdTimeout, "etcd-timeout", 5*time.Second, "the timeout of dialing and requesting etcd")
+	fs.DurationVar(&cfg.Heartbeat, "heartbeat", 2*time.Second, "how often the machine is kept alive in etcd")
+	fs.DurationVar(&cfg.BoardInterval, "board-interval", time.Second, "how often the durable CommitTs is published to the window board")
+	fs.Int64Var(&cfg.SegmentSize, "segment-size", 64*1000*1000, "the size at which a new binlog file is cut")
+	fs.IntVar(&cfg.GroupCommit, "group-commit", 128, "the max number of entries synced together, 0 disables group commit")
+	fs.StringVar(&cfg.Compression, "compression", "none", "the compression of the binlog entries: none, snappy, zstd or gzip")
+	fs.StringVar(&cfg.KeyFile, "key-file", "", "the key file to encrypt the binlog files with, empty disables encryption")
+	fs.DurationVar(&cfg.GCRetention, "gc-retention", 0, "purge the binlog files older than it, even the ones the drainers haven't pulled, 0 disables GC")
+
+	return cfg
+}
+
+func (cfg *Config) Parse(args []string) error {
+	if err := cfg.FlagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if len(cfg.FlagSet.Args()) > 0 {
+		return fmt.Errorf("'%s' is an invalid flag", cfg.FlagSet.Arg(0))
+	}
+
+	return nil
+}
+
+// BinlogOptions returns the options of the binlog files, the entries are
+// always synced before they are acknowledged
+func (cfg *Config) BinlogOptions() (*binlog.Options, error) {
+	opts := binlog.DefaultOptions()
+	opts.SegmentSize = cfg.SegmentSize
+	opts.GroupCommitMaxBatch = cfg.GroupCommit
+	opts.SyncPolicy = binlog.SyncPolicy{Mode: binlog.SyncAlways}
+
+	compression, err := parseCompression(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	opts.Compression = compression
+
+	if cfg.KeyFile != "" {
+		if opts.KeyProvider, err = binlog.NewFileKeyProvider(cfg.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GCRetention > 0 {
+		opts.GCPolicy = &binlog.GCPolicy{Retention: cfg.GCRetention}
+	}
+
+	return opts, nil
+}
+
+func parseCompression(s string) (binlog.Compression, error) {
+	for _, c := range []binlog.Compression{binlog.CompressionNone, binlog.CompressionSnappy, binlog.CompressionZstd, binlog.CompressionGzip} {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression %q", s)
+}
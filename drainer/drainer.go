@@ -0,0 +1,314 @@
+package drainer
+
+import (
+	"container/heap"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/drainer/sink"
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/registry"
+	"golang.org/x/net/context"
+)
+
+// Registry is where the drainer finds the pumps and the window board,
+// it's satisfied by registry.EtcdRegistry
+type Registry interface {
+	Machines() (map[string]*machine.MachineStatus, error)
+	GetWindowBoard() (int64, error)
+}
+
+// Drainer pulls the entries from all the live pumps and merges them into
+// one stream ordered by CommitTs. an entry is only emitted after every
+// pump has reported that all its entries up to that CommitTs are pulled,
+// and only below the window board, so the pumps which are not pulled yet
+// can't have an entry before it
+type Drainer struct {
+	opts *Options
+	reg  Registry
+	sink sink.Sink
+
+	// pumps are sorted by id, which orders the entries of the same CommitTs
+	pumps   []*pump
+	board   int64
+	notifyc chan struct{}
+	// sinkTs is the checkpoint of the sink when it's initialized, the
+	// entries up to it are in the sink already if the drainer stopped
+	// after a flush and before saving the checkpoints of the pumps
+	sinkTs int64
+
+	// checkpoints are where the pumps are pulled again from, the ones
+	// of the gone pumps are kept for them to come back
+	checkpoints map[string]*registry.Checkpoint
+	// saved are the checkpoints last saved to Options.Checkpoints
+	saved map[string]registry.Checkpoint
+}
+
+// NewDrainer returns a Drainer writing to sink, opts may be nil
+func NewDrainer(reg Registry, s sink.Sink, opts *Options) *Drainer {
+	return &Drainer{
+		opts:        opts.normalize(),
+		reg:         reg,
+		sink:        s,
+		notifyc:     make(chan struct{}, 1),
+		checkpoints: make(map[string]*registry.Checkpoint),
+		saved:       make(map[string]registry.Checkpoint),
+	}
+}
+
+// Run pulls and merges the entries until ctx is done or the sink or
+// the checkpoints fail, it returns the error of them. the sink is initialized by Run and
+// closed when it returns, the merged entries are flushed every time
+// there is nothing more to emit
+func (d *Drainer) Run(ctx context.Context) error {
+	if err := d.sink.Init(); err != nil {
+		d.sink.Close()
+		return err
+	}
+	defer d.sink.Close()
+	d.sinkTs = d.sink.Checkpoint()
+	defer d.stopPumps()
+
+	ticker := time.NewTicker(d.opts.DetectInterval)
+	defer ticker.Stop()
+
+	d.detect(ctx)
+	for {
+		if err := d.emit(); err != nil {
+			return err
+		}
+
+		select {
+		case <-d.notifyc:
+		case <-ticker.C:
+			d.detect(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// detect starts pulling from the new live pumps and stops pulling from
+// the gone ones, and then moves the window board forward. it keeps what
+// it has if the registry fails
+func (d *Drainer) detect(ctx context.Context) {
+	machines, err := d.reg.Machines()
+	if err != nil {
+		log.Errorf("failed to list the pumps: %v", err)
+	} else {
+		d.updatePumps(ctx, machines)
+	}
+
+	board, err := d.reg.GetWindowBoard()
+	if err != nil {
+		log.Errorf("failed to get the window board: %v", err)
+		return
+	}
+	if board > d.board {
+		d.board = board
+	}
+}
+
+func (d *Drainer) updatePumps(ctx context.Context, machines map[string]*machine.MachineStatus) {
+	pumps := d.pumps[:0]
+	for _, p := range d.pumps {
+		if m, ok := machines[p.id]; ok && m.IsAlive {
+			pumps = append(pumps, p)
+			continue
+		}
+
+		// the entries pulled and not emitted are pulled
+		// again from the checkpoint if it comes back
+		log.Infof("pump %s(%s) is gone", p.id, p.addr)
+		p.stop()
+		d.checkpoints[p.id] = p.checkpoint()
+	}
+	d.pumps = pumps
+
+	for id, m := range machines {
+		if !m.IsAlive || d.pump(id) != nil {
+			continue
+		}
+
+		cp, err := d.checkpoint(id)
+		if err != nil {
+			// try again at the next detection
+			log.Errorf("failed to load the checkpoint of pump %s: %v", id, err)
+			continue
+		}
+
+		addr := net.JoinHostPort(m.MachInfo.PublicIP, strconv.Itoa(d.opts.PumpPort))
+		log.Infof("start pulling from pump %s(%s) at %+v", id, addr, cp)
+		d.pumps = append(d.pumps, newPump(ctx, id, addr, cp, d.notifyc, d.opts))
+	}
+
+	sort.Slice(d.pumps, func(i, j int) bool { return d.pumps[i].id < d.pumps[j].id })
+}
+
+// checkpoint returns where the pump is pulled again from, it's loaded
+// from Options.Checkpoints for the pumps not pulled since the start
+func (d *Drainer) checkpoint(id string) (*registry.Checkpoint, error) {
+	if cp, ok := d.checkpoints[id]; ok || d.opts.Checkpoints == nil {
+		return cp, nil
+	}
+
+	cp, err := d.opts.Checkpoints.LoadCheckpoint(d.opts.ConsumerID, id)
+	if err != nil || cp == nil {
+		return nil, err
+	}
+
+	d.checkpoints[id] = cp
+	d.saved[id] = *cp
+	return cp, nil
+}
+
+func (d *Drainer) pump(id string) *pump {
+	for _, p := range d.pumps {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// emit writes the merged entries to the sink, in batches of
+// Options.BatchSize, until no entry can be emitted and flushes them
+func (d *Drainer) emit() error {
+	var written bool
+	for {
+		ents := d.merge(d.opts.BatchSize)
+		if len(ents) == 0 {
+			break
+		}
+
+		if err := d.sink.Write(ents); err != nil {
+			return err
+		}
+		written = true
+	}
+
+	if !written {
+		return nil
+	}
+	if err := d.sink.Flush(); err != nil {
+		return err
+	}
+
+	return d.saveCheckpoints()
+}
+
+// saveCheckpoints saves the checkpoints moved since the last save,
+// the failed ones are saved again after the next flush. it only fails
+// if another drainer of the same ConsumerID is ahead
+func (d *Drainer) saveCheckpoints() error {
+	for _, p := range d.pumps {
+		d.checkpoints[p.id] = p.checkpoint()
+	}
+	if d.opts.Checkpoints == nil {
+		return nil
+	}
+
+	for id, cp := range d.checkpoints {
+		if saved, ok := d.saved[id]; ok && saved == *cp {
+			continue
+		}
+
+		err := d.opts.Checkpoints.SaveCheckpoint(d.opts.ConsumerID, id, cp.Offset, cp.CommitTs)
+		if err == registry.ErrCheckpointRegressed {
+			return err
+		}
+		if err != nil {
+			log.Errorf("failed to save the checkpoint of pump %s at %+v: %v", id, cp.Offset, err)
+			continue
+		}
+		d.saved[id] = *cp
+	}
+
+	return nil
+}
+
+// merge takes at most max entries in the order of CommitTs
+// from the pumps, below the horizon. an entry written late to its pump,
+// below the horizon already passed, is taken as soon as it's pulled
+func (d *Drainer) merge(max int) []binlogscheme.Entry {
+	if len(d.pumps) == 0 {
+		return nil
+	}
+
+	horizon := d.fill()
+	for _, p := range d.pumps {
+		if horizon > p.emittedTs {
+			p.emittedTs = horizon
+		}
+	}
+
+	var ents []binlogscheme.Entry
+	for len(ents) < max {
+		var min *pump
+		for _, p := range d.pumps {
+			if len(p.ents) == 0 || p.ents[0].CommitTs >= horizon {
+				continue
+			}
+			if min == nil || p.ents[0].CommitTs < min.ents[0].CommitTs {
+				min = p
+			}
+		}
+		if min == nil {
+			break
+		}
+
+		ent := heap.Pop(&min.ents).(binlogscheme.Entry)
+		if ent.CommitTs > d.sinkTs {
+			ents = append(ents, ent)
+		}
+	}
+
+	return ents
+}
+
+// fill takes the pulled entries of the pumps and returns the horizon.
+// a pump holding back the horizon takes more than Options.PendingSize
+// entries if none of its entries is below it, since its report which
+// moves the horizon is behind them
+func (d *Drainer) fill() int64 {
+	for _, p := range d.pumps {
+		p.fill(d.opts.PendingSize)
+	}
+
+	for {
+		horizon := d.horizon()
+		var took bool
+		for _, p := range d.pumps {
+			held := p.durableTs+1 == horizon && len(p.ents) >= d.opts.PendingSize && p.ents[0].CommitTs >= horizon
+			if held && p.fill(len(p.ents)+d.opts.PendingSize) {
+				took = true
+			}
+		}
+		if !took {
+			return horizon
+		}
+	}
+}
+
+// horizon returns the CommitTs below which all the entries of the
+// live pumps are pulled, it's never beyond the window board
+func (d *Drainer) horizon() int64 {
+	horizon := d.board
+	for _, p := range d.pumps {
+		if p.durableTs+1 < horizon {
+			horizon = p.durableTs + 1
+		}
+	}
+	return horizon
+}
+
+func (d *Drainer) stopPumps() {
+	for _, p := range d.pumps {
+		p.stop()
+	}
+	d.pumps = nil
+}
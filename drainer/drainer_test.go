@@ -0,0 +1,326 @@
+package drainer
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/config"
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/pb"
+	pumpserver "github.com/pingcap/tidb-binlog/pump"
+	"github.com/pingcap/tidb-binlog/registry"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// testPump is a pump fed by the test instead of pulling
+type testPump struct {
+	*pump
+	written int64
+}
+
+// feed pulls the entries of ts in the order they are written to the
+// pump, the entries are 100 bytes apart from offset 100
+func (p *testPump) feed(ts ...int64) {
+	for _, x := range ts {
+		p.written += 100
+		p.pullc <- pulled{ent: binlogscheme.Entry{CommitTs: x, Offset: binlogscheme.BinlogOffset{Offset: p.written}}}
+	}
+}
+
+func (p *testPump) report(ts int64) {
+	p.pullc <- pulled{durableTs: ts}
+}
+
+// newTestDrainer returns a Drainer merging the test pumps of ids
+func newTestDrainer(board int64, pendingSize int, ids ...string) (*Drainer, []*testPump) {
+	d := NewDrainer(nil, nil, &Options{PendingSize: pendingSize})
+	d.board = board
+	var pumps []*testPump
+	for _, id := range ids {
+		p := &testPump{pump: &pump{id: id, pullc: make(chan pulled, 100)}}
+		d.pumps = append(d.pumps, p.pump)
+		pumps = append(pumps, p)
+	}
+	return d, pumps
+}
+
+func mergeAll(d *Drainer) []int64 {
+	var got []int64
+	for {
+		ents := d.merge(2)
+		if len(ents) == 0 {
+			return got
+		}
+		for _, ent := range ents {
+			got = append(got, ent.CommitTs)
+		}
+	}
+}
+
+func checkMerged(t *testing.T, got []int64, want ...int64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("merged %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("merged %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMergeInterleaved(t *testing.T) {
+	d, pumps := newTestDrainer(100, 10, "a", "b")
+	a, b := pumps[0], pumps[1]
+	a.feed(1, 3, 5, 7)
+	b.feed(2, 3, 4, 6)
+	a.report(7)
+	b.report(6)
+
+	// 7 waits for b to report it has nothing before it
+	checkMerged(t, mergeAll(d), 1, 2, 3, 3, 4, 5, 6)
+	b.feed(8)
+	b.report(8)
+	checkMerged(t, mergeAll(d), 7)
+
+	// only the entries below the board are emitted
+	a.report(20)
+	d.board = 8
+	checkMerged(t, mergeAll(d))
+	d.board = 9
+	checkMerged(t, mergeAll(d), 8)
+}
+
+func TestMergeOutOfOrder(t *testing.T) {
+	d, pumps := newTestDrainer(100, 10, "a")
+	a := pumps[0]
+	a.feed(5, 3, 4, 1)
+
+	// nothing is emitted before the pump reports
+	checkMerged(t, mergeAll(d))
+	a.report(3)
+	checkMerged(t, mergeAll(d), 1, 3)
+
+	// the checkpoint is at the first entry not emitted, and the
+	// entries from it below 4 are emitted
+	cp := a.checkpoint()
+	if cp.Offset.Offset != 100 || cp.CommitTs != 4 {
+		t.Fatalf("checkpoint %+v", cp)
+	}
+
+	a.feed(2)
+	a.report(5)
+	checkMerged(t, mergeAll(d), 2, 4, 5)
+	if cp = a.checkpoint(); cp.Offset.Offset != 500 || cp.CommitTs != 6 {
+		t.Fatalf("checkpoint %+v after all emitted", cp)
+	}
+}
+
+func TestMergeIdlePump(t *testing.T) {
+	d, pumps := newTestDrainer(100, 10, "a", "b")
+	a, b := pumps[0], pumps[1]
+	a.feed(1, 2, 3)
+	a.report(3)
+
+	// b has never reported
+	checkMerged(t, mergeAll(d))
+
+	// b reports with no entry, it doesn't hold a back
+	b.report(10)
+	checkMerged(t, mergeAll(d), 1, 2, 3)
+	a.feed(4)
+	a.report(4)
+	checkMerged(t, mergeAll(d), 4)
+}
+
+func TestMergeHeldPump(t *testing.T) {
+	d, pumps := newTestDrainer(100, 3, "a")
+	a := pumps[0]
+	a.report(1)
+	checkMerged(t, mergeAll(d))
+
+	// the pending entries are full and all beyond the horizon,
+	// the report moving it is behind them
+	a.feed(10, 11, 12, 13, 2)
+	a.report(13)
+	checkMerged(t, mergeAll(d), 2, 10, 11, 12, 13)
+}
+
+func TestMergeSinkCheckpoint(t *testing.T) {
+	d, pumps := newTestDrainer(100, 10, "a", "b")
+	a, b := pumps[0], pumps[1]
+
+	// the sink has the entries up to 3 from before
+	d.sinkTs = 3
+	a.feed(1, 3, 5)
+	b.feed(2, 4)
+	a.report(5)
+	b.report(5)
+	checkMerged(t, mergeAll(d), 4, 5)
+}
+
+type testRegistry struct {
+	mu       sync.Mutex
+	machines map[string]*machine.MachineStatus
+	board    int64
+}
+
+func (r *testRegistry) Machines() (map[string]*machine.MachineStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	machines := make(map[string]*machine.MachineStatus)
+	for id, m := range r.machines {
+		machines[id] = m
+	}
+	return machines, nil
+}
+
+func (r *testRegistry) GetWindowBoard() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.board, nil
+}
+
+type testSink struct {
+	mu   sync.Mutex
+	ents []binlogscheme.Entry
+
+	initErr error
+	closed  bool
+}
+
+func (s *testSink) Init() error { return s.initErr }
+
+func (s *testSink) Write(ents []binlogscheme.Entry) error {
+	s.mu.Lock()
+	s.ents = append(s.ents, ents...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *testSink) Flush() error      { return nil }
+func (s *testSink) Checkpoint() int64 { return 0 }
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *testSink) commitTs() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ts []int64
+	for _, ent := range s.ents {
+		ts = append(ts, ent.CommitTs)
+	}
+	return ts
+}
+
+// startTestPump serves a pump on a free port of the loopback
+func startTestPump(t *testing.T) (pb.PumpClient, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := config.NewConfig()
+	if err = cfg.Parse([]string{"-addr", addr, "-data-dir", t.TempDir(), "-gc-retention", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pumpserver.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(s.Close)
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	_, port, _ := net.SplitHostPort(addr)
+	n, _ := strconv.Atoi(port)
+	return pb.NewPumpClient(conn), n
+}
+
+func writeTestEntries(t *testing.T, c pb.PumpClient, ts ...int64) {
+	for _, x := range ts {
+		req := &pb.WriteBinlogReq{Entry: &pb.Entry{CommitTs: x, Payload: []byte("payload")}}
+		if _, err := c.WriteBinlog(context.Background(), req, grpc.WaitForReady(true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	c, port := startTestPump(t)
+	writeTestEntries(t, c, 3, 1, 2)
+
+	reg := &testRegistry{
+		machines: map[string]*machine.MachineStatus{
+			"a": {MachID: "a", IsAlive: true, MachInfo: machine.MachineInfo{PublicIP: "127.0.0.1"}},
+		},
+		board: 100,
+	}
+	store, err := registry.NewFileCheckpointStore(t.TempDir() + "/checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run drains into s until it has n entries
+	run := func(s *testSink, n int) {
+		d := NewDrainer(reg, s, &Options{
+			PumpPort:       port,
+			DetectInterval: 10 * time.Millisecond,
+			Checkpoints:    store,
+			ConsumerID:     "drainer",
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() { errc <- d.Run(ctx) }()
+
+		for i := 0; i < 500 && len(s.commitTs()) < n; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &testSink{}
+	run(s, 3)
+	checkMerged(t, s.commitTs(), 1, 2, 3)
+	cp, err := store.LoadCheckpoint("drainer", "a")
+	if err != nil || cp == nil || cp.CommitTs != 4 {
+		t.Fatalf("checkpoint %+v, %v", cp, err)
+	}
+
+	// the emitted entries are not emitted again
+	writeTestEntries(t, c, 5, 4)
+	s = &testSink{}
+	run(s, 2)
+	checkMerged(t, s.commitTs(), 4, 5)
+}
+
+func TestRunInitFails(t *testing.T) {
+	s := &testSink{initErr: errors.New("no destination")}
+	d := NewDrainer(nil, s, &Options{})
+	if err := d.Run(context.Background()); err != s.initErr {
+		t.Fatalf("run on a failed sink: %v", err)
+	}
+	if !s.closed {
+		t.Fatal("the failed sink is not closed")
+	}
+}
@@ -0,0 +1,69 @@
+package drainer
+
+import (
+	"time"
+
+	"github.com/pingcap/tidb-binlog/registry"
+)
+
+// Options are the settings of a Drainer
+type Options struct {
+	// PumpPort is the port of the gRPC service of the pumps,
+	// they are dialed at the public IP of their machines
+	PumpPort int
+	// DetectInterval is how often the registry is checked for
+	// the pumps coming and going and for the window board
+	DetectInterval time.Duration
+	// RetryInterval is how long a broken pull waits before pulling again
+	RetryInterval time.Duration
+	// BatchSize is the max number of entries written to the sink at once
+	BatchSize int
+	// PendingSize is the max number of entries pulled from one
+	// pump and not emitted yet, the pull blocks beyond it
+	PendingSize int
+
+	// Checkpoints keeps where the drainer is in every pump under
+	// ConsumerID, they are saved after the sink is flushed and the pumps
+	// are pulled from them after a restart. nil disables it, every pump
+	// is then pulled from its first binlog file
+	Checkpoints registry.CheckpointStore
+	ConsumerID  string
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		PumpPort:       8250,
+		DetectInterval: time.Second,
+		RetryInterval:  time.Second,
+		BatchSize:      128,
+		PendingSize:    1024,
+	}
+}
+
+// normalize returns a copy of opts with the unset fields filled
+// by the defaults, a nil opts means DefaultOptions
+func (opts *Options) normalize() *Options {
+	def := DefaultOptions()
+	if opts == nil {
+		return def
+	}
+
+	o := *opts
+	if o.PumpPort <= 0 {
+		o.PumpPort = def.PumpPort
+	}
+	if o.DetectInterval <= 0 {
+		o.DetectInterval = def.DetectInterval
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = def.RetryInterval
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = def.BatchSize
+	}
+	if o.PendingSize <= 0 {
+		o.PendingSize = def.PendingSize
+	}
+
+	return &o
+}
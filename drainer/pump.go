@@ -0,0 +1,217 @@
+package drainer
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/pb"
+	"github.com/pingcap/tidb-binlog/registry"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// pulled is an entry pulled from a pump, or the durable CommitTs
+// reported by the pump if it's not 0
+type pulled struct {
+	ent       binlogscheme.Entry
+	durableTs int64
+}
+
+// pump pulls the entries of one pump, they come in the order they are
+// written, which is not the order of CommitTs, and are kept in a heap
+// until they are emitted
+type pump struct {
+	id     string
+	addr   string
+	cancel context.CancelFunc
+	done   chan struct{}
+	// notifyc wakes up the merging goroutine after entries are pulled
+	notifyc chan<- struct{}
+
+	// pullc is filled by the pulling goroutine, the others
+	// are only touched by the merging goroutine
+	pullc chan pulled
+	ents  entHeap
+	// durableTs is the last CommitTs reported by the pump,
+	// all the entries of the pump up to it are pulled
+	durableTs int64
+	// all the entries of the pump below emittedTs are emitted
+	emittedTs int64
+	// last is the offset of the last pulled entry
+	last binlogscheme.BinlogOffset
+}
+
+// newPump starts pulling from the pump, from its first binlog file or
+// from the checkpoint cp if it's not nil
+func newPump(ctx context.Context, id, addr string, cp *registry.Checkpoint, notifyc chan<- struct{}, opts *Options) *pump {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &pump{
+		id:      id,
+		addr:    addr,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		notifyc: notifyc,
+		pullc:   make(chan pulled, opts.PendingSize),
+	}
+	if cp != nil {
+		p.emittedTs, p.last = cp.CommitTs, cp.Offset
+	}
+
+	go p.run(ctx, p.last, p.emittedTs, opts)
+	return p
+}
+
+func (p *pump) run(ctx context.Context, offset binlogscheme.BinlogOffset, emittedTs int64, opts *Options) {
+	defer close(p.done)
+
+	var after bool
+	for {
+		err := p.pull(ctx, &offset, &after, emittedTs)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnf("failed to pull binlogs from pump %s(%s) at %+v: %v", p.id, p.addr, offset, err)
+
+		select {
+		case <-time.After(opts.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pull streams the entries into pullc until an error, the entries below
+// emittedTs are dropped. offset is the last entry pulled if after is true,
+// so the next pull starts from it and skips it
+func (p *pump) pull(ctx context.Context, offset *binlogscheme.BinlogOffset, after *bool, emittedTs int64) error {
+	conn, err := grpc.DialContext(ctx, p.addr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewPumpClient(conn).PullBinlogs(ctx, &pb.PullBinlogReq{
+		Start: &pb.Offset{Index: offset.Index, Offset: offset.Offset},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range resp.Entries {
+			ent := binlogscheme.Entry{
+				CommitTs: e.CommitTs,
+				StartTs:  e.StartTs,
+				Size:     int64(len(e.Payload)),
+				Payload:  e.Payload,
+			}
+			if e.Offset != nil {
+				ent.Offset = binlogscheme.BinlogOffset{Index: e.Offset.Index, Offset: e.Offset.Offset}
+			}
+			if (*after && ent.Offset == *offset) || ent.CommitTs < emittedTs {
+				continue
+			}
+
+			if err = p.send(ctx, pulled{ent: ent}); err != nil {
+				return err
+			}
+			*offset, *after = ent.Offset, true
+		}
+
+		if resp.DurableTs > 0 {
+			if err = p.send(ctx, pulled{durableTs: resp.DurableTs}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case p.notifyc <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *pump) send(ctx context.Context, pl pulled) error {
+	select {
+	case p.pullc <- pl:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fill takes the pulled entries until max of them are kept,
+// it returns whether anything is taken
+func (p *pump) fill(max int) bool {
+	var took bool
+	for len(p.ents) < max {
+		select {
+		case pl := <-p.pullc:
+			took = true
+			if pl.durableTs > p.durableTs {
+				p.durableTs = pl.durableTs
+			}
+			if pl.durableTs == 0 {
+				heap.Push(&p.ents, pl.ent)
+				p.last = pl.ent.Offset
+			}
+		default:
+			return took
+		}
+	}
+	return took
+}
+
+// checkpoint returns where the pump is pulled again from after a restart,
+// the entries from it below emittedTs are emitted and the others are not.
+// it's the first entry kept, or the last pulled one if none is kept
+func (p *pump) checkpoint() *registry.Checkpoint {
+	offset := p.last
+	for _, ent := range p.ents {
+		if offsetBefore(ent.Offset, offset) {
+			offset = ent.Offset
+		}
+	}
+	return &registry.Checkpoint{Offset: offset, CommitTs: p.emittedTs}
+}
+
+// stop stops pulling and waits for the pulling goroutine to quit
+func (p *pump) stop() {
+	p.cancel()
+	<-p.done
+}
+
+// entHeap is a min-heap of entries by CommitTs, and then by offset
+type entHeap []binlogscheme.Entry
+
+func (h entHeap) Len() int { return len(h) }
+
+func (h entHeap) Less(i, j int) bool {
+	if h[i].CommitTs != h[j].CommitTs {
+		return h[i].CommitTs < h[j].CommitTs
+	}
+	return offsetBefore(h[i].Offset, h[j].Offset)
+}
+
+func (h entHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *entHeap) Push(x interface{}) { *h = append(*h, x.(binlogscheme.Entry)) }
+
+func (h *entHeap) Pop() interface{} {
+	old := *h
+	ent := old[len(old)-1]
+	old[len(old)-1] = binlogscheme.Entry{}
+	*h = old[:len(old)-1]
+	return ent
+}
+
+func offsetBefore(a, b binlogscheme.BinlogOffset) bool {
+	return a.Index < b.Index || (a.Index == b.Index && a.Offset < b.Offset)
+}
@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// FileSink writes the entries into a local binlog directory, which is
+// cut into binlog files by Options.SegmentSize like the ones of a pump
+type FileSink struct {
+	dir  string
+	opts *binlog.Options
+
+	b    *binlog.Binlog
+	ents []binlogscheme.Entry
+	// lastTs is of the last written entry, checkpoint of the last flushed one
+	lastTs     int64
+	checkpoint int64
+}
+
+// NewFileSink returns a FileSink writing to dir, it's created by Init
+// if it doesn't exist yet. the entries are only synced by Flush whatever
+// the SyncPolicy of opts is, opts may be nil
+func NewFileSink(dir string, opts *binlog.Options) *FileSink {
+	o := binlog.DefaultOptions()
+	if opts != nil {
+		*o = *opts
+	}
+	o.SyncPolicy = binlog.SyncPolicy{Mode: binlog.SyncNever}
+	o.GroupCommitMaxBatch = 0
+
+	return &FileSink{dir: dir, opts: o}
+}
+
+// Init opens the directory for appending, the checkpoint
+// is taken from the last entry already in it
+func (s *FileSink) Init() error {
+	var err error
+	if binlog.Exist(s.dir) {
+		s.b, err = binlog.OpenForWrite(s.dir, s.opts)
+	} else {
+		s.b, err = binlog.Create(s.dir, s.opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = s.loadCheckpoint(); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// loadCheckpoint takes the CommitTs of the last entry as the checkpoint
+func (s *FileSink) loadCheckpoint() error {
+	it, err := s.b.NewReverseIterator()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	if it.Prev() {
+		s.checkpoint = it.Entry().CommitTs
+	}
+	s.lastTs = s.checkpoint
+	return it.Err()
+}
+
+func (s *FileSink) Write(ents []binlogscheme.Entry) error {
+	if len(ents) == 0 {
+		return nil
+	}
+
+	// Write of Binlog fills the offsets of the entries
+	s.ents = append(s.ents[:0], ents...)
+	if err := s.b.Write(s.ents); err != nil {
+		return err
+	}
+
+	s.lastTs = ents[len(ents)-1].CommitTs
+	return nil
+}
+
+func (s *FileSink) Flush() error {
+	if err := s.b.Sync(); err != nil {
+		return err
+	}
+
+	s.checkpoint = s.lastTs
+	return nil
+}
+
+func (s *FileSink) Checkpoint() int64 {
+	return s.checkpoint
+}
+
+func (s *FileSink) Close() error {
+	if s.b == nil {
+		return nil
+	}
+	b := s.b
+	s.b = nil
+	return b.Close()
+}
@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// jsonEntry is one line of the JSON sink, the payload is base64 encoded
+type jsonEntry struct {
+	CommitTs int64  `json:"commit-ts"`
+	StartTs  int64  `json:"start-ts"`
+	Payload  []byte `json:"payload"`
+}
+
+// JSONSink writes the entries to a writer, one JSON object a line
+type JSONSink struct {
+	w   io.Writer
+	bw  *bufio.Writer
+	enc *json.Encoder
+
+	lastTs     int64
+	checkpoint int64
+}
+
+// NewJSONSink returns a JSONSink writing to w, like os.Stdout. w
+// is not closed by Close. the checkpoint starts from 0 every time
+func NewJSONSink(w io.Writer) *JSONSink {
+	bw := bufio.NewWriter(w)
+	return &JSONSink{w: w, bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (s *JSONSink) Init() error {
+	return nil
+}
+
+func (s *JSONSink) Write(ents []binlogscheme.Entry) error {
+	for i := range ents {
+		ent := &ents[i]
+		if err := s.enc.Encode(jsonEntry{
+			CommitTs: ent.CommitTs,
+			StartTs:  ent.StartTs,
+			Payload:  ent.Payload,
+		}); err != nil {
+			return err
+		}
+		s.lastTs = ent.CommitTs
+	}
+
+	return nil
+}
+
+func (s *JSONSink) Flush() error {
+	if err := s.bw.Flush(); err != nil {
+		return err
+	}
+
+	s.checkpoint = s.lastTs
+	return nil
+}
+
+func (s *JSONSink) Checkpoint() int64 {
+	return s.checkpoint
+}
+
+func (s *JSONSink) Close() error {
+	return s.Flush()
+}
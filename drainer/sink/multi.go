@@ -0,0 +1,66 @@
+package sink
+
+import "github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+
+type multiSink struct {
+	sinks []Sink
+}
+
+// Multi returns a Sink writing to all the sinks in turn, it fails
+// as soon as one of them fails. its checkpoint is the lowest of theirs
+func Multi(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks}
+}
+
+// Init initializes the sinks in turn, the ones initialized
+// already are closed if one of them fails
+func (m *multiSink) Init() error {
+	for i, s := range m.sinks {
+		if err := s.Init(); err != nil {
+			for _, inited := range m.sinks[:i] {
+				inited.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiSink) Write(ents []binlogscheme.Entry) error {
+	for _, s := range m.sinks {
+		if err := s.Write(ents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiSink) Flush() error {
+	for _, s := range m.sinks {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiSink) Checkpoint() int64 {
+	var ts int64
+	for i, s := range m.sinks {
+		if cp := s.Checkpoint(); i == 0 || cp < ts {
+			ts = cp
+		}
+	}
+	return ts
+}
+
+// Close closes all the sinks and returns the first error
+func (m *multiSink) Close() error {
+	var err error
+	for _, s := range m.sinks {
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
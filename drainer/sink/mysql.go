@@ -0,0 +1,198 @@
+package sink
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+const (
+	createEntryTable = `CREATE TABLE IF NOT EXISTS binlog_entry (
+	commit_ts BIGINT NOT NULL,
+	start_ts BIGINT NOT NULL,
+	payload LONGBLOB,
+	PRIMARY KEY (commit_ts, start_ts))`
+
+	createCheckpointTable = `CREATE TABLE IF NOT EXISTS binlog_checkpoint (
+	id INT NOT NULL PRIMARY KEY,
+	commit_ts BIGINT NOT NULL)`
+
+	insertEntries = "REPLACE INTO binlog_entry (commit_ts, start_ts, payload) VALUES "
+
+	// the max number of rows of one statement, a prepared
+	// statement takes at most 65535 placeholders
+	maxInsertRows = 65535 / 3
+	// the bytes of a row in a statement besides its payload,
+	// which takes twice its size if it's escaped
+	rowOverhead = 64
+)
+
+// MySQLSink writes the entries into the table binlog_entry of a database
+// speaking the MySQL protocol, like MySQL or TiDB. the entries written
+// between two Flush are committed in one transaction together with the
+// checkpoint in the table binlog_checkpoint, so the checkpoint matches the
+// entries in the table. a failed Write or Flush drops the entries since
+// the last Flush, they have to be written again from the checkpoint.
+// an entry written twice is replaced. the entries of a Write are split
+// into statements fitting max_allowed_packet of the server, or the
+// maxAllowedPacket of the dsn if it's lower
+type MySQLSink struct {
+	dsn    string
+	driver string
+
+	db        *sql.DB
+	tx        *sql.Tx
+	maxPacket int
+
+	lastTs     int64
+	checkpoint int64
+}
+
+// NewMySQLSink returns a MySQLSink connecting to dsn, which is in the
+// format of github.com/go-sql-driver/mysql, e.g. user:password@tcp(host:port)/db
+func NewMySQLSink(dsn string) *MySQLSink {
+	return &MySQLSink{dsn: dsn, driver: "mysql"}
+}
+
+// Init creates the tables if they don't exist and loads the checkpoint
+func (s *MySQLSink) Init() error {
+	cfg, err := mysql.ParseDSN(s.dsn)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(s.driver, s.dsn)
+	if err != nil {
+		return err
+	}
+
+	if err = s.setup(db, cfg); err != nil {
+		db.Close()
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+// setup creates the tables and loads the checkpoint and max_allowed_packet
+func (s *MySQLSink) setup(db *sql.DB, cfg *mysql.Config) error {
+	for _, stmt := range []string{createEntryTable, createCheckpointTable} {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	err := db.QueryRow("SELECT commit_ts FROM binlog_checkpoint WHERE id = 1").Scan(&s.checkpoint)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err = db.QueryRow("SELECT @@max_allowed_packet").Scan(&s.maxPacket); err != nil {
+		return err
+	}
+	if cfg.MaxAllowedPacket > 0 && cfg.MaxAllowedPacket < s.maxPacket {
+		s.maxPacket = cfg.MaxAllowedPacket
+	}
+
+	s.lastTs = s.checkpoint
+	return nil
+}
+
+func (s *MySQLSink) Write(ents []binlogscheme.Entry) error {
+	if len(ents) == 0 {
+		return nil
+	}
+
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		s.tx = tx
+	}
+
+	last := ents[len(ents)-1].CommitTs
+	for len(ents) > 0 {
+		n := s.batchLen(ents)
+		if err := s.insert(ents[:n]); err != nil {
+			s.rollback()
+			return err
+		}
+		ents = ents[n:]
+	}
+
+	s.lastTs = last
+	return nil
+}
+
+// batchLen returns how many of the first entries fit in one statement,
+// an entry larger than max_allowed_packet is written alone
+func (s *MySQLSink) batchLen(ents []binlogscheme.Entry) int {
+	size := len(insertEntries)
+	for i := range ents {
+		size += 2*len(ents[i].Payload) + rowOverhead
+		if i > 0 && (size > s.maxPacket || i == maxInsertRows) {
+			return i
+		}
+	}
+	return len(ents)
+}
+
+func (s *MySQLSink) insert(ents []binlogscheme.Entry) error {
+	args := make([]interface{}, 0, len(ents)*3)
+	for i := range ents {
+		args = append(args, ents[i].CommitTs, ents[i].StartTs, ents[i].Payload)
+	}
+
+	query := insertEntries + strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(ents)), ", ")
+	_, err := s.tx.Exec(query, args...)
+	return err
+}
+
+// Flush commits the entries written since the last Flush
+func (s *MySQLSink) Flush() error {
+	if s.tx == nil {
+		return nil
+	}
+
+	_, err := s.tx.Exec("REPLACE INTO binlog_checkpoint (id, commit_ts) VALUES (1, ?)", s.lastTs)
+	if err != nil {
+		s.rollback()
+		return err
+	}
+
+	err = s.tx.Commit()
+	s.tx = nil
+	if err != nil {
+		return err
+	}
+
+	s.checkpoint = s.lastTs
+	return nil
+}
+
+// rollback drops the entries written since the last Flush
+func (s *MySQLSink) rollback() {
+	s.tx.Rollback()
+	s.tx = nil
+	s.lastTs = s.checkpoint
+}
+
+func (s *MySQLSink) Checkpoint() int64 {
+	return s.checkpoint
+}
+
+// Close drops the entries which are not flushed
+func (s *MySQLSink) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	if s.tx != nil {
+		s.rollback()
+	}
+	db := s.db
+	s.db = nil
+	return db.Close()
+}
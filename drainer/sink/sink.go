@@ -0,0 +1,21 @@
+package sink
+
+import "github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+
+// Sink is where the drainer writes the merged entries to, in the order
+// of CommitTs. the entries of one Write are only taken by the sink until
+// it returns, it copies what it keeps
+type Sink interface {
+	// Init prepares the destination, it's called once before the first
+	// Write. a failed Init releases what it has opened, Close does nothing then
+	Init() error
+	// Write adds the entries, they may be buffered until Flush
+	Write(ents []binlogscheme.Entry) error
+	// Flush makes the written entries durable in the destination
+	Flush() error
+	// Checkpoint returns the CommitTs of the last flushed entry, or 0 if
+	// no entry has ever been flushed. the drainer skips the entries up to
+	// it after Init, they are in the destination already
+	Checkpoint() int64
+	Close() error
+}
@@ -0,0 +1,314 @@
+package sink
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+)
+
+// testEntries returns the entries of ts, the payload of ts is of size bytes
+func testEntries(size int, ts ...int64) []binlogscheme.Entry {
+	var ents []binlogscheme.Entry
+	for _, x := range ts {
+		payload := bytes.Repeat([]byte(fmt.Sprint(x%10)), size)
+		ents = append(ents, binlogscheme.Entry{CommitTs: x, StartTs: x - 1, Payload: payload})
+	}
+	return ents
+}
+
+func TestFileSink(t *testing.T) {
+	dir := path.Join(t.TempDir(), "sink")
+	opts := binlog.DefaultOptions()
+	opts.SegmentSize = 1024
+
+	s := NewFileSink(dir, opts)
+	if err := s.Init(); err != nil || s.Checkpoint() != 0 {
+		t.Fatalf("checkpoint %d of a new sink, %v", s.Checkpoint(), err)
+	}
+	in := testEntries(200, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	if err := s.Write(in[:6]); err != nil {
+		t.Fatal(err)
+	}
+	if in[3].Offset != (binlogscheme.BinlogOffset{}) {
+		t.Fatal("the written entries are changed")
+	}
+	if s.Checkpoint() != 0 {
+		t.Fatalf("checkpoint %d before the flush", s.Checkpoint())
+	}
+	if err := s.Flush(); err != nil || s.Checkpoint() != 6 {
+		t.Fatalf("checkpoint %d after the flush, %v", s.Checkpoint(), err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reopened sink takes the checkpoint from the last entry
+	s = NewFileSink(dir, opts)
+	if err := s.Init(); err != nil || s.Checkpoint() != 6 {
+		t.Fatalf("checkpoint %d of a reopened sink, %v", s.Checkpoint(), err)
+	}
+	if err := s.Write(in[6:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	r, err := binlog.Open(dir, &binlogscheme.BinlogOffset{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	out, err := r.Read(100)
+	if err != io.EOF || len(out) != len(in) {
+		t.Fatalf("read %d entries, %v", len(out), err)
+	}
+	for i := range in {
+		if out[i].CommitTs != in[i].CommitTs || out[i].StartTs != in[i].StartTs || !bytes.Equal(out[i].Payload, in[i].Payload) {
+			t.Fatalf("entry %d is %+v, want %+v", i, out[i], in[i])
+		}
+	}
+	if out[len(out)-1].Offset.Index == 0 {
+		t.Fatal("the sink is not cut by the segment size")
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONSink(&buf)
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(testEntries(3, 1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 || s.Checkpoint() != 0 {
+		t.Fatalf("%d bytes and checkpoint %d before the flush", buf.Len(), s.Checkpoint())
+	}
+	if err := s.Flush(); err != nil || s.Checkpoint() != 3 {
+		t.Fatalf("checkpoint %d after the flush, %v", s.Checkpoint(), err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/json.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(golden) {
+		t.Fatalf("the JSON sink writes\n%s\nwant\n%s", buf.String(), golden)
+	}
+}
+
+// the mock databases are registered by dsn for good
+var mockDBs int
+
+// newMockSink returns a MySQLSink on a mock database, which takes the
+// statements of Init with no checkpoint saved. params are of the dsn
+func newMockSink(t *testing.T, params string, maxPacket int) (*MySQLSink, sqlmock.Sqlmock) {
+	s, mock := newMockDB(t, params)
+	mock.ExpectExec(regexp.QuoteMeta(createEntryTable)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(createCheckpointTable)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT commit_ts FROM binlog_checkpoint").WillReturnRows(sqlmock.NewRows([]string{"commit_ts"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@max_allowed_packet")).
+		WillReturnRows(sqlmock.NewRows([]string{"@@max_allowed_packet"}).AddRow(maxPacket))
+
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return s, mock
+}
+
+// newMockDB returns a MySQLSink on a mock database without initializing it
+func newMockDB(t *testing.T, params string) (*MySQLSink, sqlmock.Sqlmock) {
+	mockDBs++
+	dsn := fmt.Sprintf("root@tcp(127.0.0.1:3306)/binlog%d%s", mockDBs, params)
+	db, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := NewMySQLSink(dsn)
+	s.driver = "sqlmock"
+	return s, mock
+}
+
+// expectInsert expects the entries to be written in one statement
+func expectInsert(mock sqlmock.Sqlmock, ents []binlogscheme.Entry) *sqlmock.ExpectedExec {
+	var args []driver.Value
+	for _, ent := range ents {
+		args = append(args, ent.CommitTs, ent.StartTs, ent.Payload)
+	}
+	return mock.ExpectExec(regexp.QuoteMeta(insertEntries)).WithArgs(args...)
+}
+
+func TestMySQLSink(t *testing.T) {
+	s, mock := newMockSink(t, "", 1<<20)
+	in := testEntries(10, 1, 2, 3)
+
+	mock.ExpectBegin()
+	expectInsert(mock, in).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("REPLACE INTO binlog_checkpoint").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := s.Write(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil || s.Checkpoint() != 3 {
+		t.Fatalf("checkpoint %d after the flush, %v", s.Checkpoint(), err)
+	}
+
+	// a failed write drops the entries since the last flush
+	more := testEntries(10, 4, 5)
+	mock.ExpectBegin()
+	expectInsert(mock, more).WillReturnError(errors.New("lost connection"))
+	mock.ExpectRollback()
+	if err := s.Write(more); err == nil {
+		t.Fatal("write succeeds with the statement failing")
+	}
+	if s.Checkpoint() != 3 || s.lastTs != 3 {
+		t.Fatalf("checkpoint %d and last %d after a failed write", s.Checkpoint(), s.lastTs)
+	}
+
+	mock.ExpectClose()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLSinkSplit(t *testing.T) {
+	// the dsn allows less than the server
+	s, mock := newMockSink(t, "?maxAllowedPacket=1000", 4000)
+	if s.maxPacket != 1000 {
+		t.Fatalf("max packet %d, want the 1000 of the dsn", s.maxPacket)
+	}
+
+	// about 3 entries of 100 bytes fit in a statement, the large one goes alone
+	in := append(testEntries(100, 1, 2, 3, 4, 5), testEntries(2000, 6)...)
+	in = append(in, testEntries(100, 7)...)
+	mock.ExpectBegin()
+	for _, batch := range [][]binlogscheme.Entry{in[:3], in[3:5], in[5:6], in[6:]} {
+		expectInsert(mock, batch).WillReturnResult(sqlmock.NewResult(0, int64(len(batch))))
+	}
+	mock.ExpectExec("REPLACE INTO binlog_checkpoint").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.Write(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil || s.Checkpoint() != 7 {
+		t.Fatalf("checkpoint %d after the flush, %v", s.Checkpoint(), err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	dir := path.Join(t.TempDir(), "sink")
+	var buf bytes.Buffer
+	js := NewJSONSink(&buf)
+	s := Multi(NewFileSink(dir, nil), js)
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Write(testEntries(3, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil || s.Checkpoint() != 2 {
+		t.Fatalf("checkpoint %d after the flush, %v", s.Checkpoint(), err)
+	}
+	if err := js.Write(testEntries(3, 3)); err != nil {
+		t.Fatal(err)
+	}
+	js.Flush()
+	if s.Checkpoint() != 2 {
+		t.Fatalf("checkpoint %d, want the lowest of the sinks", s.Checkpoint())
+	}
+}
+
+func TestMySQLSinkInitFails(t *testing.T) {
+	s, mock := newMockDB(t, "")
+	mock.ExpectExec(regexp.QuoteMeta(createEntryTable)).WillReturnError(errors.New("access denied"))
+	mock.ExpectClose()
+
+	if err := s.Init(); err == nil {
+		t.Fatal("init without the privileges")
+	}
+	// the database is closed by Init
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSinkInitFails(t *testing.T) {
+	dir := path.Join(t.TempDir(), "sink")
+	opts := binlog.DefaultOptions()
+	opts.SegmentSize = 1024
+	b, err := binlog.Create(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stop right after a cut, so the last entry is in a sealed file
+	for i := int64(1); b.LastOffset().Index == 0; i++ {
+		if err = b.Write(testEntries(200, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Close()
+
+	// corrupt the payload of the last entry
+	f, err := os.OpenFile(path.Join(dir, fmt.Sprintf("%016d.bl", 0)), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.WriteAt([]byte{'!'}, info.Size()-16)
+	}
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewFileSink(dir, opts)
+	if err = s.Init(); !binlog.IsCorruption(err) {
+		t.Fatalf("init on a corrupted entry: %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the binlog is unlocked
+	if b, err = binlog.OpenForWrite(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	// the initialized sinks are closed if another one fails
+	dir = path.Join(t.TempDir(), "sink")
+	if err = Multi(NewFileSink(dir, opts), NewMySQLSink("bad dsn")).Init(); err == nil {
+		t.Fatal("init with a bad dsn")
+	}
+	if b, err = binlog.OpenForWrite(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+}
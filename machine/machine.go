@@ -27,11 +27,10 @@ type Machine interface {
 }
 
 type machine struct {
-	machID     string
-	hostName   string
-	publicIP   string
-	offset     *localfile.Offset
-	rwMutex    sync.RWMutex
+	machID   string
+	hostName string
+	publicIP string
+	rwMutex  sync.RWMutex
 }
 
 func NewMachineFromConfig(cfg *config.Config) (Machine, error) {
@@ -62,9 +61,9 @@ func NewMachineFromConfig(cfg *config.Config) (Machine, error) {
 	}
 
 	mach := &machine{
-		machID:     machID,
-		hostName:   hostName,
-		publicIP:   publicIP,
+		machID:   machID,
+		hostName: hostName,
+		publicIP: publicIP,
 	}
 	return mach, nil
 }
@@ -136,11 +135,10 @@ func (m *machine) MatchID(ID string) bool {
 }
 
 func (m *machine) Info() *MachineInfo {
-	return &MachineInfo {
-		HostName:	m.hostName,
-		PublicIP:	m.publicIP,
-		Offset:		m.offset,	
-		}
+	return &MachineInfo{
+		HostName: m.hostName,
+		PublicIP: m.publicIP,
+	}
 }
 
 func (m *machine) Status() *MachineStatus {
@@ -148,9 +146,8 @@ func (m *machine) Status() *MachineStatus {
 		MachID:  m.machID,
 		IsAlive: true,
 		MachInfo: MachineInfo{
-			HostName:   m.hostName,
-			PublicIP:   m.publicIP,
-			Offset:	    m.offset,
-		}
+			HostName: m.hostName,
+			PublicIP: m.publicIP,
+		},
 	}
 }
@@ -7,8 +7,8 @@ type MachineStatus struct {
 }
 
 type MachineInfo struct {
-	HostName   string
-	PublicIP   string
-	FileName   uint16
-	Offset	   uint16
+	HostName string
+	PublicIP string
+	FileName uint16
+	Offset   uint16
 }
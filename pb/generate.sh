@@ -0,0 +1,4 @@
+#!/bin/sh
+# regenerate pump.pb.go, needs protoc and protoc-gen-go v1.3
+cd "$(dirname "$0")"
+protoc --go_out=plugins=grpc:. pump.proto
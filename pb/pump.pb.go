@@ -0,0 +1,490 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pump.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Offset is the position of an entry in the binlog files of a pump
+type Offset struct {
+	Index                uint64   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Offset               int64    `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Offset) Reset()         { *m = Offset{} }
+func (m *Offset) String() string { return proto.CompactTextString(m) }
+func (*Offset) ProtoMessage()    {}
+func (*Offset) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{0}
+}
+
+func (m *Offset) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Offset.Unmarshal(m, b)
+}
+func (m *Offset) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Offset.Marshal(b, m, deterministic)
+}
+func (m *Offset) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Offset.Merge(m, src)
+}
+func (m *Offset) XXX_Size() int {
+	return xxx_messageInfo_Offset.Size(m)
+}
+func (m *Offset) XXX_DiscardUnknown() {
+	xxx_messageInfo_Offset.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Offset proto.InternalMessageInfo
+
+func (m *Offset) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Offset) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type Entry struct {
+	CommitTs             int64    `protobuf:"varint,1,opt,name=commitTs,proto3" json:"commitTs,omitempty"`
+	StartTs              int64    `protobuf:"varint,2,opt,name=startTs,proto3" json:"startTs,omitempty"`
+	Payload              []byte   `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	Offset               *Offset  `protobuf:"bytes,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+func (*Entry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{1}
+}
+
+func (m *Entry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Entry.Unmarshal(m, b)
+}
+func (m *Entry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Entry.Marshal(b, m, deterministic)
+}
+func (m *Entry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Entry.Merge(m, src)
+}
+func (m *Entry) XXX_Size() int {
+	return xxx_messageInfo_Entry.Size(m)
+}
+func (m *Entry) XXX_DiscardUnknown() {
+	xxx_messageInfo_Entry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Entry proto.InternalMessageInfo
+
+func (m *Entry) GetCommitTs() int64 {
+	if m != nil {
+		return m.CommitTs
+	}
+	return 0
+}
+
+func (m *Entry) GetStartTs() int64 {
+	if m != nil {
+		return m.StartTs
+	}
+	return 0
+}
+
+func (m *Entry) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Entry) GetOffset() *Offset {
+	if m != nil {
+		return m.Offset
+	}
+	return nil
+}
+
+type WriteBinlogReq struct {
+	Entry                *Entry   `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteBinlogReq) Reset()         { *m = WriteBinlogReq{} }
+func (m *WriteBinlogReq) String() string { return proto.CompactTextString(m) }
+func (*WriteBinlogReq) ProtoMessage()    {}
+func (*WriteBinlogReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{2}
+}
+
+func (m *WriteBinlogReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteBinlogReq.Unmarshal(m, b)
+}
+func (m *WriteBinlogReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteBinlogReq.Marshal(b, m, deterministic)
+}
+func (m *WriteBinlogReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteBinlogReq.Merge(m, src)
+}
+func (m *WriteBinlogReq) XXX_Size() int {
+	return xxx_messageInfo_WriteBinlogReq.Size(m)
+}
+func (m *WriteBinlogReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteBinlogReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteBinlogReq proto.InternalMessageInfo
+
+func (m *WriteBinlogReq) GetEntry() *Entry {
+	if m != nil {
+		return m.Entry
+	}
+	return nil
+}
+
+type WriteBinlogResp struct {
+	// offset is where the entry is written
+	Offset               *Offset  `protobuf:"bytes,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteBinlogResp) Reset()         { *m = WriteBinlogResp{} }
+func (m *WriteBinlogResp) String() string { return proto.CompactTextString(m) }
+func (*WriteBinlogResp) ProtoMessage()    {}
+func (*WriteBinlogResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{3}
+}
+
+func (m *WriteBinlogResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteBinlogResp.Unmarshal(m, b)
+}
+func (m *WriteBinlogResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteBinlogResp.Marshal(b, m, deterministic)
+}
+func (m *WriteBinlogResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteBinlogResp.Merge(m, src)
+}
+func (m *WriteBinlogResp) XXX_Size() int {
+	return xxx_messageInfo_WriteBinlogResp.Size(m)
+}
+func (m *WriteBinlogResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteBinlogResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteBinlogResp proto.InternalMessageInfo
+
+func (m *WriteBinlogResp) GetOffset() *Offset {
+	if m != nil {
+		return m.Offset
+	}
+	return nil
+}
+
+type PullBinlogReq struct {
+	// start is the offset of the first entry to pull
+	Start                *Offset  `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PullBinlogReq) Reset()         { *m = PullBinlogReq{} }
+func (m *PullBinlogReq) String() string { return proto.CompactTextString(m) }
+func (*PullBinlogReq) ProtoMessage()    {}
+func (*PullBinlogReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{4}
+}
+
+func (m *PullBinlogReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PullBinlogReq.Unmarshal(m, b)
+}
+func (m *PullBinlogReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PullBinlogReq.Marshal(b, m, deterministic)
+}
+func (m *PullBinlogReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PullBinlogReq.Merge(m, src)
+}
+func (m *PullBinlogReq) XXX_Size() int {
+	return xxx_messageInfo_PullBinlogReq.Size(m)
+}
+func (m *PullBinlogReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PullBinlogReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PullBinlogReq proto.InternalMessageInfo
+
+func (m *PullBinlogReq) GetStart() *Offset {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+type PullBinlogResp struct {
+	Entries []*Entry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	// durableTs is sent once the entries before are all the ones of
+	// the pump up to it, it's 0 in the other responses
+	DurableTs            int64    `protobuf:"varint,2,opt,name=durableTs,proto3" json:"durableTs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PullBinlogResp) Reset()         { *m = PullBinlogResp{} }
+func (m *PullBinlogResp) String() string { return proto.CompactTextString(m) }
+func (*PullBinlogResp) ProtoMessage()    {}
+func (*PullBinlogResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3d140275d3b8185f, []int{5}
+}
+
+func (m *PullBinlogResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PullBinlogResp.Unmarshal(m, b)
+}
+func (m *PullBinlogResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PullBinlogResp.Marshal(b, m, deterministic)
+}
+func (m *PullBinlogResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PullBinlogResp.Merge(m, src)
+}
+func (m *PullBinlogResp) XXX_Size() int {
+	return xxx_messageInfo_PullBinlogResp.Size(m)
+}
+func (m *PullBinlogResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_PullBinlogResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PullBinlogResp proto.InternalMessageInfo
+
+func (m *PullBinlogResp) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *PullBinlogResp) GetDurableTs() int64 {
+	if m != nil {
+		return m.DurableTs
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Offset)(nil), "pb.Offset")
+	proto.RegisterType((*Entry)(nil), "pb.Entry")
+	proto.RegisterType((*WriteBinlogReq)(nil), "pb.WriteBinlogReq")
+	proto.RegisterType((*WriteBinlogResp)(nil), "pb.WriteBinlogResp")
+	proto.RegisterType((*PullBinlogReq)(nil), "pb.PullBinlogReq")
+	proto.RegisterType((*PullBinlogResp)(nil), "pb.PullBinlogResp")
+}
+
+func init() {
+	proto.RegisterFile("pump.proto", fileDescriptor_3d140275d3b8185f)
+}
+
+var fileDescriptor_3d140275d3b8185f = []byte{
+	// 301 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x92, 0x5f, 0x4b, 0xc3, 0x30,
+	0x14, 0xc5, 0xcd, 0xba, 0x6e, 0xee, 0x4e, 0x27, 0x5e, 0x45, 0xca, 0x10, 0x2c, 0xf1, 0xa5, 0x4f,
+	0x43, 0x2b, 0x8a, 0xcf, 0x82, 0xcf, 0x8e, 0x28, 0xf8, 0xdc, 0xda, 0x4c, 0x0a, 0x6d, 0x13, 0x9b,
+	0x14, 0x9c, 0x7e, 0x79, 0x49, 0x6a, 0xff, 0xa9, 0xf8, 0x78, 0xee, 0xe9, 0xaf, 0xe7, 0xdc, 0x4b,
+	0x00, 0x64, 0x95, 0xcb, 0x95, 0x2c, 0x85, 0x16, 0x38, 0x92, 0x31, 0xbd, 0x81, 0xc9, 0xc3, 0x66,
+	0xa3, 0xb8, 0xc6, 0x63, 0x70, 0xd3, 0x22, 0xe1, 0xef, 0x1e, 0xf1, 0x49, 0x30, 0x66, 0xb5, 0xc0,
+	0x13, 0x98, 0x08, 0xeb, 0x7b, 0x23, 0x9f, 0x04, 0x0e, 0xfb, 0x56, 0xf4, 0x13, 0xdc, 0xfb, 0x42,
+	0x97, 0x5b, 0x5c, 0xc2, 0xee, 0x8b, 0xc8, 0xf3, 0x54, 0x3f, 0x29, 0x4b, 0x3a, 0xac, 0xd5, 0xe8,
+	0xc1, 0x54, 0xe9, 0xa8, 0x34, 0x56, 0x4d, 0x37, 0xd2, 0x38, 0x32, 0xda, 0x66, 0x22, 0x4a, 0x3c,
+	0xc7, 0x27, 0xc1, 0x1e, 0x6b, 0x24, 0xd2, 0x36, 0x70, 0xec, 0x93, 0x60, 0x1e, 0xc2, 0x4a, 0xc6,
+	0xab, 0xba, 0x62, 0x1b, 0x7e, 0x09, 0x8b, 0xe7, 0x32, 0xd5, 0xfc, 0x2e, 0x2d, 0x32, 0xf1, 0xca,
+	0xf8, 0x1b, 0x9e, 0x81, 0xcb, 0x4d, 0x1d, 0x5b, 0x61, 0x1e, 0xce, 0x0c, 0x64, 0xfb, 0xb1, 0x7a,
+	0x4e, 0xaf, 0xe1, 0x60, 0x80, 0x28, 0xd9, 0x4b, 0x22, 0xff, 0x24, 0xed, 0xaf, 0xab, 0x2c, 0xeb,
+	0x82, 0x7c, 0x70, 0xed, 0x0e, 0x7f, 0x30, 0xb5, 0x41, 0x1f, 0x61, 0xd1, 0x47, 0x94, 0xc4, 0x73,
+	0x98, 0x9a, 0x12, 0x29, 0x37, 0x17, 0x72, 0x86, 0xf5, 0x1a, 0x07, 0x4f, 0x61, 0x96, 0x54, 0x65,
+	0x14, 0x67, 0xbc, 0xbd, 0x56, 0x37, 0x08, 0x3f, 0x60, 0xbc, 0xae, 0x72, 0x89, 0xb7, 0x30, 0xef,
+	0xad, 0x81, 0x68, 0x7e, 0x34, 0x3c, 0xc5, 0xf2, 0xe8, 0xd7, 0x4c, 0x49, 0xba, 0x63, 0xc8, 0xae,
+	0x96, 0xc2, 0x43, 0xf3, 0xd5, 0x60, 0xb5, 0x25, 0xfe, 0x1c, 0x19, 0xee, 0x82, 0xc4, 0x13, 0xfb,
+	0x5a, 0xae, 0xbe, 0x06, 0x00, 0x57, 0x0a, 0xea, 0x2d, 0x3b, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// PumpClient is the client API for Pump service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PumpClient interface {
+	// WriteBinlog appends an entry, it returns after the entry is durable
+	WriteBinlog(ctx context.Context, in *WriteBinlogReq, opts ...grpc.CallOption) (*WriteBinlogResp, error)
+	// PullBinlogs streams the entries from the offset, and keeps
+	// streaming the new ones as they are written
+	PullBinlogs(ctx context.Context, in *PullBinlogReq, opts ...grpc.CallOption) (Pump_PullBinlogsClient, error)
+}
+
+type pumpClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPumpClient(cc grpc.ClientConnInterface) PumpClient {
+	return &pumpClient{cc}
+}
+
+func (c *pumpClient) WriteBinlog(ctx context.Context, in *WriteBinlogReq, opts ...grpc.CallOption) (*WriteBinlogResp, error) {
+	out := new(WriteBinlogResp)
+	err := c.cc.Invoke(ctx, "/pb.Pump/WriteBinlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pumpClient) PullBinlogs(ctx context.Context, in *PullBinlogReq, opts ...grpc.CallOption) (Pump_PullBinlogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Pump_serviceDesc.Streams[0], "/pb.Pump/PullBinlogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pumpPullBinlogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Pump_PullBinlogsClient interface {
+	Recv() (*PullBinlogResp, error)
+	grpc.ClientStream
+}
+
+type pumpPullBinlogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *pumpPullBinlogsClient) Recv() (*PullBinlogResp, error) {
+	m := new(PullBinlogResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PumpServer is the server API for Pump service.
+type PumpServer interface {
+	// WriteBinlog appends an entry, it returns after the entry is durable
+	WriteBinlog(context.Context, *WriteBinlogReq) (*WriteBinlogResp, error)
+	// PullBinlogs streams the entries from the offset, and keeps
+	// streaming the new ones as they are written
+	PullBinlogs(*PullBinlogReq, Pump_PullBinlogsServer) error
+}
+
+// UnimplementedPumpServer can be embedded to have forward compatible implementations.
+type UnimplementedPumpServer struct {
+}
+
+func (*UnimplementedPumpServer) WriteBinlog(ctx context.Context, req *WriteBinlogReq) (*WriteBinlogResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteBinlog not implemented")
+}
+func (*UnimplementedPumpServer) PullBinlogs(req *PullBinlogReq, srv Pump_PullBinlogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method PullBinlogs not implemented")
+}
+
+func RegisterPumpServer(s *grpc.Server, srv PumpServer) {
+	s.RegisterService(&_Pump_serviceDesc, srv)
+}
+
+func _Pump_WriteBinlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteBinlogReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PumpServer).WriteBinlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Pump/WriteBinlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PumpServer).WriteBinlog(ctx, req.(*WriteBinlogReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pump_PullBinlogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PullBinlogReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PumpServer).PullBinlogs(m, &pumpPullBinlogsServer{stream})
+}
+
+type Pump_PullBinlogsServer interface {
+	Send(*PullBinlogResp) error
+	grpc.ServerStream
+}
+
+type pumpPullBinlogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *pumpPullBinlogsServer) Send(m *PullBinlogResp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Pump_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Pump",
+	HandlerType: (*PumpServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "WriteBinlog",
+			Handler:    _Pump_WriteBinlog_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PullBinlogs",
+			Handler:       _Pump_PullBinlogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pump.proto",
+}
@@ -1,9 +1,10 @@
 package pkg
 
 import (
-	"github.com/docker/libcontainer/netlink"
+	"bufio"
 	"github.com/ngaut/log"
 	"net"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -106,24 +107,29 @@ func usableAddress(ip net.IP) bool {
 }
 
 func getDefaultGatewayIface() *net.Interface {
-	log.Debug("Attempting to retrieve IP route info from netlink")
-	routes, err := netlink.NetworkGetRoutes()
+	log.Debug("Attempting to retrieve IP route info from /proc/net/route")
+	f, err := os.Open("/proc/net/route")
 	if err != nil {
 		log.Debugf("Unable to detect default interface: %v", err)
 		return nil
 	}
-	if len(routes) == 0 {
-		log.Debug("Netlink returned zero routes")
-		return nil
-	}
-	for _, route := range routes {
-		if route.Default {
-			if route.Iface == nil {
-				log.Debugf("Found default route but could not determine interface")
-			}
-			log.Debugf("Found default route with interface %v", route.Iface.Name)
-			return route.Iface
+	defer f.Close()
+
+	// Iface Destination Gateway Flags ..., the default route has destination 0
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[1] != "00000000" {
+			continue
+		}
+		iface, err := net.InterfaceByName(fields[0])
+		if err != nil {
+			log.Debugf("Found default route but could not determine interface")
+			return nil
 		}
+		log.Debugf("Found default route with interface %v", iface.Name)
+		return iface
 	}
 	log.Debugf("Unable to find default route")
 	return nil
@@ -0,0 +1,209 @@
+package pump
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/machine"
+	"golang.org/x/net/context"
+)
+
+// BoardRegistry is what a pump needs to take part in the window board,
+// it's satisfied by registry.EtcdRegistry
+type BoardRegistry interface {
+	Machines() (map[string]*machine.MachineStatus, error)
+	GetWindowBoard() (int64, error)
+	PumpTs() (map[string]int64, error)
+	UpdatePumpTs(pumpID string, ts int64) error
+	AdvanceWindowBoard(machines map[string]*machine.MachineStatus) (int64, error)
+}
+
+// durableTs tracks the CommitTs up to which all the entries written to
+// the pump are synced. an entry being written holds it below its CommitTs,
+// so a smaller CommitTs still in flight isn't skipped over. a write below
+// it is still taken, it's a late one the drainers emit once they pull it
+type durableTs struct {
+	mu       sync.Mutex
+	synced   int64
+	inflight map[int64]int
+	// idle is the CommitTs the pump is durable up to while nothing is
+	// in flight, raised by the board when nothing is written to the pump
+	idle int64
+	// busy is whether a write begins or ends since the last idle check
+	busy bool
+}
+
+func newDurableTs() *durableTs {
+	return &durableTs{inflight: make(map[int64]int)}
+}
+
+// begin starts the write of the entry
+func (d *durableTs) begin(ts int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inflight[ts]++
+	d.busy = true
+}
+
+// done ends the write of the entry, ok tells whether it's synced
+func (d *durableTs) done(ts int64, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.inflight[ts]--; d.inflight[ts] == 0 {
+		delete(d.inflight, ts)
+	}
+	if ok && ts > d.synced {
+		d.synced = ts
+	}
+	d.busy = true
+}
+
+// get returns the durable CommitTs, the higher of the last synced entry
+// and the idle one, held below the lowest CommitTs in flight
+func (d *durableTs) get() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ts := d.synced
+	if d.idle > ts {
+		ts = d.idle
+	}
+	for inflight := range d.inflight {
+		if inflight <= ts {
+			ts = inflight - 1
+		}
+	}
+	return ts
+}
+
+// raise raises the idle CommitTs to ts
+func (d *durableTs) raise(ts int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if ts > d.idle {
+		d.idle = ts
+	}
+}
+
+// raiseIdle raises the idle CommitTs to ts, or right above the last synced
+// entry if it's higher, when nothing is written since the last call.
+// it returns whether the pump is idle
+func (d *durableTs) raiseIdle(ts int64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	idle := !d.busy && len(d.inflight) == 0
+	d.busy = false
+	if !idle {
+		return false
+	}
+
+	if d.synced+1 > ts {
+		ts = d.synced + 1
+	}
+	if ts > d.idle {
+		d.idle = ts
+	}
+	return true
+}
+
+// JoinBoard raises the idle CommitTs to the window board and to the one
+// published by the pump as machID before, so a restarted pump doesn't
+// report less than it did. it's called before the pump serves
+func (s *Server) JoinBoard(reg BoardRegistry, machID string) error {
+	board, err := reg.GetWindowBoard()
+	if err != nil {
+		return err
+	}
+	pumpTs, err := reg.PumpTs()
+	if err != nil {
+		return err
+	}
+
+	s.durable.raise(board)
+	s.durable.raise(pumpTs[machID])
+	return nil
+}
+
+// RunBoard publishes the durable CommitTs of the pump as machID every
+// interval until ctx is done. the pump of the lowest live machine ID is
+// the leader and advances the window board, two pumps taking themselves
+// as the leader for a while is harmless since the board only moves forward.
+// the board is the lowest of the CommitTs published, each is held below
+// the writes in flight of its pump, and a pump with nothing written during
+// an interval publishes the highest CommitTs published by the pumps, or
+// right above its last entry, so it doesn't hold the board back. no write
+// is rejected, a late one below the board is emitted once it's pulled
+func (s *Server) RunBoard(ctx context.Context, reg BoardRegistry, machID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := s.raiseIdle(reg); err != nil {
+			log.Errorf("failed to get the CommitTs of the pumps: %v", err)
+			continue
+		}
+
+		if ts := s.durable.get(); ts > 0 {
+			if err := reg.UpdatePumpTs(machID, ts); err != nil {
+				log.Errorf("failed to publish the CommitTs %d of pump %s: %v", ts, machID, err)
+				continue
+			}
+		}
+
+		machines, err := reg.Machines()
+		if err != nil {
+			log.Errorf("failed to list the pumps: %v", err)
+			continue
+		}
+		if !isBoardLeader(machID, machines) {
+			continue
+		}
+
+		if _, err = reg.AdvanceWindowBoard(machines); err != nil {
+			log.Errorf("failed to advance the window board: %v", err)
+		}
+	}
+}
+
+// raiseIdle raises the idle CommitTs of an idle pump to the highest
+// CommitTs published by the pumps
+func (s *Server) raiseIdle(reg BoardRegistry) error {
+	pumpTs, err := reg.PumpTs()
+	if err != nil {
+		return err
+	}
+
+	var max int64
+	for _, ts := range pumpTs {
+		if ts > max {
+			max = ts
+		}
+	}
+	s.durable.raiseIdle(max)
+	return nil
+}
+
+// isBoardLeader returns whether machID is the lowest of the live machines
+func isBoardLeader(machID string, machines map[string]*machine.MachineStatus) bool {
+	var alive []string
+	for id, m := range machines {
+		if m.IsAlive {
+			alive = append(alive, id)
+		}
+	}
+
+	sort.Strings(alive)
+	return len(alive) > 0 && alive[0] == machID
+}
@@ -0,0 +1,163 @@
+package pump
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/pb"
+	"github.com/pingcap/tidb-binlog/registry"
+	"github.com/pingcap/tidb-binlog/util/etcdutil/etcdtest"
+	"golang.org/x/net/context"
+)
+
+func TestDurableTs(t *testing.T) {
+	d := newDurableTs()
+	d.begin(5)
+	d.begin(3)
+
+	// 3 in flight holds it below 3
+	d.done(5, true)
+	if ts := d.get(); ts != 2 {
+		t.Fatalf("durable ts %d with 3 in flight, want 2", ts)
+	}
+	d.done(3, false)
+	if ts := d.get(); ts != 5 {
+		t.Fatalf("durable ts %d, want 5", ts)
+	}
+
+	// a late write below it is taken and holds it below its CommitTs
+	d.begin(4)
+	if ts := d.get(); ts != 3 {
+		t.Fatalf("durable ts %d with the late 4 in flight, want 3", ts)
+	}
+	d.done(4, true)
+	d.begin(6)
+	d.done(6, true)
+	if ts := d.get(); ts != 6 {
+		t.Fatalf("durable ts %d, want 6", ts)
+	}
+}
+
+func TestDurableTsIdle(t *testing.T) {
+	d := newDurableTs()
+	d.begin(5)
+
+	// a write since the last check, or one in flight, is not idle
+	if d.raiseIdle(100) {
+		t.Fatal("idle with an entry in flight")
+	}
+	d.done(5, true)
+	if d.raiseIdle(100) {
+		t.Fatal("idle right after a write")
+	}
+	if ts := d.get(); ts != 5 {
+		t.Fatalf("durable ts %d, want 5", ts)
+	}
+
+	// an idle pump is raised right above its last entry at least
+	if !d.raiseIdle(3) {
+		t.Fatal("not idle without writes")
+	}
+	if ts := d.get(); ts != 6 {
+		t.Fatalf("durable ts %d of an idle pump, want 6", ts)
+	}
+	if !d.raiseIdle(100) {
+		t.Fatal("not idle without writes")
+	}
+	if ts := d.get(); ts != 100 {
+		t.Fatalf("durable ts %d of an idle pump, want 100", ts)
+	}
+
+	// a late write to an idle pump holds it below its CommitTs
+	d.begin(50)
+	if ts := d.get(); ts != 49 {
+		t.Fatalf("durable ts %d with the late 50 in flight, want 49", ts)
+	}
+	d.done(50, true)
+	if ts := d.get(); ts != 100 {
+		t.Fatalf("durable ts %d, want 100", ts)
+	}
+}
+
+func TestIsBoardLeader(t *testing.T) {
+	machines := map[string]*machine.MachineStatus{
+		"a": {MachID: "a", IsAlive: false},
+		"b": {MachID: "b", IsAlive: true},
+		"c": {MachID: "c", IsAlive: true},
+	}
+	if isBoardLeader("a", machines) || !isBoardLeader("b", machines) || isBoardLeader("c", machines) {
+		t.Fatal("the leader is not the lowest live machine")
+	}
+}
+
+// testBoardRegistry lists the pumps as given instead of their registrations
+type testBoardRegistry struct {
+	*registry.EtcdRegistry
+	machines map[string]*machine.MachineStatus
+}
+
+func (r *testBoardRegistry) Machines() (map[string]*machine.MachineStatus, error) {
+	return r.machines, nil
+}
+
+// waitBoard waits for the window board to reach board
+func waitBoard(t *testing.T, reg BoardRegistry, board int64) {
+	t.Helper()
+	var got int64
+	for i := 0; i < 500; i++ {
+		var err error
+		if got, err = reg.GetWindowBoard(); err != nil {
+			t.Fatal(err)
+		}
+		if got == board {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("window board %d, want %d", got, board)
+}
+
+func TestRunBoard(t *testing.T) {
+	reg := &testBoardRegistry{
+		EtcdRegistry: registry.NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second),
+		machines: map[string]*machine.MachineStatus{
+			"a": {MachID: "a", IsAlive: true},
+			"b": {MachID: "b", IsAlive: true},
+		},
+	}
+	a, ca := newTestServer(t)
+	b, _ := newTestServer(t)
+	writeTestBinlogs(t, ca, 1, 42)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for id, s := range map[string]*Server{"a": a, "b": b} {
+		if err := s.JoinBoard(reg, id); err != nil {
+			t.Fatal(err)
+		}
+		go s.RunBoard(ctx, reg, id, 10*time.Millisecond)
+	}
+
+	// the idle b doesn't hold the board back, which moves right
+	// above the last entry, so the drainers emit it
+	waitBoard(t, reg, 43)
+
+	// a late write behind the board is taken
+	if _, err := ca.WriteBinlog(context.Background(), &pb.WriteBinlogReq{Entry: &pb.Entry{CommitTs: 40}}); err != nil {
+		t.Fatalf("write behind the board: %v", err)
+	}
+
+	writeTestBinlogs(t, ca, 50, 60)
+	waitBoard(t, reg, 61)
+	cancel()
+
+	// a restarted pump doesn't publish less than the board
+	c, _ := newTestServer(t)
+	if err := c.JoinBoard(reg, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ts := c.durable.get(); ts != 61 {
+		t.Fatalf("durable ts %d after a restart, want 61", ts)
+	}
+}
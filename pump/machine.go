@@ -0,0 +1,61 @@
+package pump
+
+import (
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/machine"
+	"golang.org/x/net/context"
+)
+
+// MachineRegistry is where a pump registers its machine,
+// it's satisfied by registry.EtcdRegistry
+type MachineRegistry interface {
+	RegisterMachine(machID, hostName, publicIP string) error
+	RefreshMachine(machID string, ttl int64) error
+}
+
+// machineTTL is how long the machine stays alive without a heartbeat,
+// a few heartbeats may be missed before the machine is taken as gone
+func machineTTL(heartbeat time.Duration) int64 {
+	ttl := int64((3*heartbeat + time.Second - 1) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	return ttl
+}
+
+// Register registers the machine of the pump with its host name and IP,
+// and keeps it alive by refreshing its TTL every heartbeat until ctx is
+// done. the machine is alive when Register returns
+func Register(ctx context.Context, reg MachineRegistry, m machine.Machine, heartbeat time.Duration) error {
+	status := m.Status()
+	if err := reg.RegisterMachine(status.MachID, status.MachInfo.HostName, status.MachInfo.PublicIP); err != nil {
+		return err
+	}
+
+	ttl := machineTTL(heartbeat)
+	if err := reg.RefreshMachine(status.MachID, ttl); err != nil {
+		return err
+	}
+	log.Infof("registered machine %s of host %s(%s)", status.MachID, status.MachInfo.HostName, status.MachInfo.PublicIP)
+
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			if err := reg.RefreshMachine(status.MachID, ttl); err != nil {
+				log.Errorf("failed to keep machine %s alive: %v", status.MachID, err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package pump
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/registry"
+	"github.com/pingcap/tidb-binlog/util/etcdutil/etcdtest"
+	"golang.org/x/net/context"
+)
+
+type testMachine struct {
+	id   string
+	host string
+}
+
+func (m *testMachine) ID() string             { return m.id }
+func (m *testMachine) ShortID() string        { return m.id }
+func (m *testMachine) MatchID(id string) bool { return m.id == id }
+func (m *testMachine) Status() *machine.MachineStatus {
+	return &machine.MachineStatus{
+		MachID:   m.id,
+		IsAlive:  true,
+		MachInfo: machine.MachineInfo{HostName: m.host, PublicIP: "10.0.0.1"},
+	}
+}
+
+func TestMachineTTL(t *testing.T) {
+	for heartbeat, ttl := range map[time.Duration]int64{
+		10 * time.Millisecond:   1,
+		time.Second:             3,
+		1500 * time.Millisecond: 5,
+	} {
+		if got := machineTTL(heartbeat); got != ttl {
+			t.Fatalf("ttl of heartbeat %v is %d, want %d", heartbeat, got, ttl)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	reg := registry.NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+	m := &testMachine{id: "a", host: "pump-a"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := Register(ctx, reg, m, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := reg.Machine("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsAlive || status.MachInfo != m.Status().MachInfo {
+		t.Fatalf("registered machine %+v, want %+v", status, m.Status())
+	}
+
+	// the heartbeats keep it alive past its ttl
+	time.Sleep(3 * time.Second)
+	if status, err = reg.Machine("a"); err != nil || !status.IsAlive {
+		t.Fatalf("machine %+v after the heartbeats, %v", status, err)
+	}
+
+	// it's gone a while after the heartbeats stop
+	cancel()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if status, err = reg.Machine("a"); err != nil {
+			t.Fatal(err)
+		}
+		if !status.IsAlive {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("machine is still alive without heartbeats")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// registering again updates the host
+	m.host = "pump-a2"
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if err = Register(ctx, reg, m, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = reg.Machine("a"); err != nil || !status.IsAlive || status.MachInfo.HostName != "pump-a2" {
+		t.Fatalf("machine %+v after registering again, %v", status, err)
+	}
+}
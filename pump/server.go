@@ -0,0 +1,206 @@
+package pump
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/binlog"
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/config"
+	"github.com/pingcap/tidb-binlog/pb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Server serves the Pump gRPC service on the binlog files of a pump
+type Server struct {
+	cfg    *config.Config
+	binlog *binlog.Binlog
+	gs     *grpc.Server
+	// durable is published to the window board by RunBoard
+	durable *durableTs
+
+	closeOnce sync.Once
+}
+
+// NewServer opens the binlog directory of cfg for writing, it's created
+// if it doesn't exist yet
+func NewServer(cfg *config.Config) (*Server, error) {
+	opts, err := cfg.BinlogOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	var b *binlog.Binlog
+	if binlog.Exist(cfg.DataDir) {
+		b, err = binlog.OpenForWrite(cfg.DataDir, opts)
+	} else {
+		b, err = binlog.Create(cfg.DataDir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cfg:     cfg,
+		binlog:  b,
+		gs:      grpc.NewServer(),
+		durable: newDurableTs(),
+	}
+	pb.RegisterPumpServer(s.gs, s)
+	return s, nil
+}
+
+// Start serves on cfg.ListenAddr, it blocks until the server is closed
+func (s *Server) Start() error {
+	l, err := net.Listen("tcp", s.cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("pump is serving on %s", s.cfg.ListenAddr)
+	return s.serve(l)
+}
+
+func (s *Server) serve(l net.Listener) error {
+	err := s.gs.Serve(l)
+	if err == grpc.ErrServerStopped {
+		return nil
+	}
+	return err
+}
+
+// WriteBinlog appends the entry, it returns after the entry is synced.
+// the entry holds the durable CommitTs below it while it's written
+func (s *Server) WriteBinlog(ctx context.Context, req *pb.WriteBinlogReq) (*pb.WriteBinlogResp, error) {
+	if req.Entry == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no entry to write")
+	}
+
+	ents := []binlogscheme.Entry{{
+		CommitTs: req.Entry.CommitTs,
+		StartTs:  req.Entry.StartTs,
+		Payload:  req.Entry.Payload,
+	}}
+	s.durable.begin(ents[0].CommitTs)
+	err := s.binlog.Write(ents)
+	s.durable.done(ents[0].CommitTs, err == nil)
+	if err != nil {
+		log.Errorf("failed to write binlog: %v", err)
+		return nil, grpc.Errorf(codes.Internal, "failed to write binlog: %v", err)
+	}
+
+	return &pb.WriteBinlogResp{Offset: toPBOffset(ents[0].Offset)}, nil
+}
+
+// the interval to report the durable CommitTs to the pulling clients
+var durableReportInterval = time.Second
+
+// PullBinlogs streams the entries from the start offset until the client
+// goes away, the new entries are sent as soon as they are written. the
+// durable CommitTs is reported once the entries before it are sent, which
+// tells the client it has all the entries of the pump up to it
+func (s *Server) PullBinlogs(req *pb.PullBinlogReq, stream pb.Pump_PullBinlogsServer) error {
+	var start binlogscheme.BinlogOffset
+	if req.Start != nil {
+		start = binlogscheme.BinlogOffset{Index: req.Start.Index, Offset: req.Start.Offset}
+	}
+
+	// check the offset before streaming from it, the reader is closed
+	// after the watch pins the files, so GC can't purge them in between
+	r, err := s.binlog.NewReader(start)
+	if err != nil {
+		return grpc.Errorf(pullErrorCode(err), "can't pull binlogs from %+v: %v", start, err)
+	}
+	wc := s.binlog.Watch(stream.Context(), start)
+	r.Close()
+
+	ticker := time.NewTicker(durableReportInterval)
+	defer ticker.Stop()
+
+	// the entries up to durable are all before the end taken after it,
+	// pos is zero until the watch tells where it is
+	var pos binlogscheme.BinlogOffset
+	var reported int64
+	durable, end := s.durable.get(), s.binlog.LastOffset()
+	for {
+		select {
+		case resp, ok := <-wc:
+			if !ok {
+				return stream.Context().Err()
+			}
+			if err = s.sendEntries(stream, resp); err != nil {
+				return err
+			}
+			pos = resp.Next
+		case <-ticker.C:
+		}
+
+		if offsetBefore(pos, end) {
+			continue
+		}
+		if durable > reported {
+			if err = stream.Send(&pb.PullBinlogResp{DurableTs: durable}); err != nil {
+				return err
+			}
+			reported = durable
+		}
+		durable, end = s.durable.get(), s.binlog.LastOffset()
+	}
+}
+
+func (s *Server) sendEntries(stream pb.Pump_PullBinlogsServer, resp binlog.WatchResponse) error {
+	if resp.Err != nil {
+		log.Errorf("failed to pull binlogs: %v", resp.Err)
+		return grpc.Errorf(codes.Internal, "failed to pull binlogs: %v", resp.Err)
+	}
+
+	ents := make([]*pb.Entry, 0, len(resp.Entries))
+	for i := range resp.Entries {
+		ent := &resp.Entries[i]
+		ents = append(ents, &pb.Entry{
+			CommitTs: ent.CommitTs,
+			StartTs:  ent.StartTs,
+			Payload:  ent.Payload,
+			Offset:   toPBOffset(ent.Offset),
+		})
+	}
+
+	if len(ents) == 0 {
+		return nil
+	}
+	return stream.Send(&pb.PullBinlogResp{Entries: ents})
+}
+
+func offsetBefore(a, b binlogscheme.BinlogOffset) bool {
+	return a.Index < b.Index || (a.Index == b.Index && a.Offset < b.Offset)
+}
+
+func pullErrorCode(err error) codes.Code {
+	switch err {
+	case binlog.ErrFileNotFound:
+		return codes.NotFound
+	case binlog.ErrBadOffset:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+func toPBOffset(offset binlogscheme.BinlogOffset) *pb.Offset {
+	return &pb.Offset{Index: offset.Index, Offset: offset.Offset}
+}
+
+// Close stops serving and closes the binlog files, the connections
+// are closed at once since the pulling streams never end by themselves
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		s.gs.Stop()
+		if err := s.binlog.Close(); err != nil {
+			log.Errorf("failed to close binlog: %v", err)
+		}
+	})
+}
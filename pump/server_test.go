@@ -0,0 +1,177 @@
+package pump
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/config"
+	"github.com/pingcap/tidb-binlog/pb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestServer serves a pump on a temporary directory through an
+// in-memory connection and returns a client of it
+func newTestServer(t *testing.T) (*Server, pb.PumpClient) {
+	cfg := config.NewConfig()
+	if err := cfg.Parse([]string{"-data-dir", t.TempDir(), "-gc-retention", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := bufconn.Listen(1 << 20)
+	done := make(chan error, 1)
+	go func() { done <- s.serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return s, pb.NewPumpClient(conn)
+}
+
+func writeTestBinlogs(t *testing.T, c pb.PumpClient, from, to int64) []*pb.Offset {
+	var offsets []*pb.Offset
+	for ts := from; ts <= to; ts++ {
+		resp, err := c.WriteBinlog(context.Background(), &pb.WriteBinlogReq{Entry: &pb.Entry{CommitTs: ts, Payload: []byte("payload")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, resp.Offset)
+	}
+	return offsets
+}
+
+func TestWriteAndPull(t *testing.T) {
+	_, c := newTestServer(t)
+	offsets := writeTestBinlogs(t, c, 1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.PullBinlogs(ctx, &pb.PullBinlogReq{Start: offsets[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := int64(4)
+	recv := func(to int64) {
+		for next <= to {
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ent := range resp.Entries {
+				if ent.CommitTs != next {
+					t.Fatalf("pulled CommitTs %d, want %d", ent.CommitTs, next)
+				}
+				if next <= 10 && (ent.Offset.Index != offsets[next-1].Index || ent.Offset.Offset != offsets[next-1].Offset) {
+					t.Fatalf("pulled entry %d at %+v, want %+v", next, ent.Offset, offsets[next-1])
+				}
+				next++
+			}
+		}
+	}
+	recv(10)
+
+	// the new entries are streamed as they are written
+	writeTestBinlogs(t, c, 11, 15)
+	recv(15)
+
+	// the durable CommitTs may be reported before the stream ends
+	cancel()
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	if grpc.Code(err) != codes.Canceled {
+		t.Fatalf("pull after cancel: %v", err)
+	}
+}
+
+func TestPullDurableTs(t *testing.T) {
+	defer func(d time.Duration) { durableReportInterval = d }(durableReportInterval)
+	durableReportInterval = 10 * time.Millisecond
+
+	_, c := newTestServer(t)
+	writeTestBinlogs(t, c, 1, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.PullBinlogs(ctx, &pb.PullBinlogReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// recv pulls until durable is reported, a report
+	// is never ahead of the entries pulled before it
+	var pulled int64
+	recv := func(durable int64) {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ent := range resp.Entries {
+				if ent.CommitTs != pulled+1 {
+					t.Fatalf("pulled CommitTs %d after %d", ent.CommitTs, pulled)
+				}
+				pulled++
+			}
+			if resp.DurableTs > pulled {
+				t.Fatalf("durable CommitTs %d reported after entry %d", resp.DurableTs, pulled)
+			}
+			if resp.DurableTs == durable {
+				return
+			}
+		}
+	}
+
+	recv(5)
+	writeTestBinlogs(t, c, 6, 8)
+	recv(8)
+}
+
+func TestWriteNoEntry(t *testing.T) {
+	_, c := newTestServer(t)
+	if _, err := c.WriteBinlog(context.Background(), &pb.WriteBinlogReq{}); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("write no entry: %v", err)
+	}
+}
+
+func TestPullBadOffset(t *testing.T) {
+	_, c := newTestServer(t)
+	offsets := writeTestBinlogs(t, c, 1, 3)
+
+	for _, tc := range []struct {
+		start *pb.Offset
+		code  codes.Code
+	}{
+		{&pb.Offset{Index: 100}, codes.NotFound},
+		{&pb.Offset{Index: offsets[1].Index, Offset: offsets[1].Offset + 3}, codes.InvalidArgument},
+	} {
+		stream, err := c.PullBinlogs(context.Background(), &pb.PullBinlogReq{Start: tc.start})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if grpc.Code(err) != tc.code {
+			t.Fatalf("pull from %+v: %v, want %v", tc.start, err, tc.code)
+		}
+	}
+}
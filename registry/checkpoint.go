@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"errors"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
+)
+
+const checkpointPrefix = "checkpoint"
+
+// ErrCheckpointRegressed is returned when a checkpoint is saved behind the
+// one already saved, which means another consumer of the same ID is ahead
+var ErrCheckpointRegressed = errors.New("registry: checkpoint moves backward")
+
+// Checkpoint is the last entry a consumer has consumed from a pump
+type Checkpoint struct {
+	Offset   binlogscheme.BinlogOffset `json:"offset"`
+	CommitTs int64                     `json:"commit-ts"`
+}
+
+// CheckpointStore keeps the checkpoints of the consumers, one for every
+// pump they consume from. a checkpoint only moves forward
+type CheckpointStore interface {
+	// SaveCheckpoint returns ErrCheckpointRegressed if offset is
+	// before the saved one, saving the same offset again is a no-op
+	SaveCheckpoint(consumerID, pumpID string, offset binlogscheme.BinlogOffset, commitTs int64) error
+	// LoadCheckpoint returns nil if nothing has been saved
+	LoadCheckpoint(consumerID, pumpID string) (*Checkpoint, error)
+}
+
+// SaveCheckpoint compares and swaps the checkpoint with the saved one,
+// and tries again if it's changed in between
+func (r *EtcdRegistry) SaveCheckpoint(consumerID, pumpID string, offset binlogscheme.BinlogOffset, commitTs int64) error {
+	object, err := marshal(&Checkpoint{Offset: offset, CommitTs: commitTs})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.ctx()
+	defer cancel()
+	key := r.prefixed(checkpointPrefix, consumerID, pumpID)
+	for {
+		val, revision, err := r.client.GetWithRevision(ctx, key)
+		if err != nil && !etcd.IsNotFound(err) {
+			return err
+		}
+
+		if err == nil {
+			saved := &Checkpoint{}
+			if err = unmarshal(string(val), saved); err != nil {
+				return err
+			}
+			if err = checkForward(saved, offset); err != nil || saved.Offset == offset {
+				return err
+			}
+		}
+
+		err = r.client.CompareAndSwap(ctx, key, object, revision)
+		if !etcd.IsRevisionConflict(err) {
+			return err
+		}
+	}
+}
+
+func (r *EtcdRegistry) LoadCheckpoint(consumerID, pumpID string) (*Checkpoint, error) {
+	ctx, cancel := r.ctx()
+	defer cancel()
+	val, err := r.client.Get(ctx, r.prefixed(checkpointPrefix, consumerID, pumpID))
+	if etcd.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{}
+	if err = unmarshal(string(val), cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// checkForward returns ErrCheckpointRegressed if offset is before the saved checkpoint
+func checkForward(saved *Checkpoint, offset binlogscheme.BinlogOffset) error {
+	if offset.Index < saved.Offset.Index ||
+		(offset.Index == saved.Offset.Index && offset.Offset < saved.Offset.Offset) {
+		return ErrCheckpointRegressed
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/fileutil"
+)
+
+// FileCheckpointStore keeps the checkpoints in one JSON file, for the
+// setups of a single node. it's rewritten atomically on every save
+type FileCheckpointStore struct {
+	path string
+
+	mu sync.Mutex
+	// checkpoints are indexed by the consumer ID and then the pump ID
+	checkpoints map[string]map[string]*Checkpoint
+}
+
+// NewFileCheckpointStore loads the checkpoints of the file,
+// it's created by the first save if it doesn't exist
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		path:        path,
+		checkpoints: make(map[string]map[string]*Checkpoint),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &s.checkpoints); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileCheckpointStore) SaveCheckpoint(consumerID, pumpID string, offset binlogscheme.BinlogOffset, commitTs int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := s.checkpoints[consumerID][pumpID]
+	if saved != nil {
+		if err := checkForward(saved, offset); err != nil || saved.Offset == offset {
+			return err
+		}
+	}
+
+	if s.checkpoints[consumerID] == nil {
+		s.checkpoints[consumerID] = make(map[string]*Checkpoint)
+	}
+	s.checkpoints[consumerID][pumpID] = &Checkpoint{Offset: offset, CommitTs: commitTs}
+
+	if err := s.save(); err != nil {
+		// keep what's in the file
+		if saved == nil {
+			delete(s.checkpoints[consumerID], pumpID)
+		} else {
+			s.checkpoints[consumerID][pumpID] = saved
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileCheckpointStore) LoadCheckpoint(consumerID, pumpID string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := s.checkpoints[consumerID][pumpID]
+	if cp == nil {
+		return nil, nil
+	}
+
+	copied := *cp
+	return &copied, nil
+}
+
+// save writes the checkpoints to a temporary file and renames it
+func (s *FileCheckpointStore) save() error {
+	data, err := json.Marshal(s.checkpoints)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileutil.PrivateFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = fileutil.Fsync(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(s.path+".tmp", s.path)
+}
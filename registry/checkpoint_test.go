@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/binlog/binlogscheme"
+	"github.com/pingcap/tidb-binlog/util/etcdutil/etcdtest"
+)
+
+func off(index uint64, offset int64) binlogscheme.BinlogOffset {
+	return binlogscheme.BinlogOffset{Index: index, Offset: offset}
+}
+
+func testCheckpointStore(t *testing.T, s CheckpointStore) {
+	cp, err := s.LoadCheckpoint("d1", "p1")
+	if err != nil || cp != nil {
+		t.Fatalf("load a missing checkpoint: %v, %v", cp, err)
+	}
+
+	if err = s.SaveCheckpoint("d1", "p1", off(1, 100), 5); err != nil {
+		t.Fatal(err)
+	}
+	// saving the same offset again is a no-op
+	if err = s.SaveCheckpoint("d1", "p1", off(1, 100), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SaveCheckpoint("d1", "p1", off(1, 50), 4); err != ErrCheckpointRegressed {
+		t.Fatalf("save an offset backward in the segment: %v", err)
+	}
+	if err = s.SaveCheckpoint("d1", "p1", off(0, 500), 4); err != ErrCheckpointRegressed {
+		t.Fatalf("save an offset in a segment before: %v", err)
+	}
+	if err = s.SaveCheckpoint("d1", "p1", off(2, 32), 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SaveCheckpoint("d2", "p1", off(0, 32), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = s.LoadCheckpoint("d1", "p1")
+	if err != nil || cp == nil || cp.Offset != off(2, 32) || cp.CommitTs != 7 {
+		t.Fatalf("load d1: %+v, %v", cp, err)
+	}
+	cp, err = s.LoadCheckpoint("d2", "p1")
+	if err != nil || cp == nil || cp.Offset != off(0, 32) || cp.CommitTs != 1 {
+		t.Fatalf("load d2: %+v, %v", cp, err)
+	}
+	if cp, _ = s.LoadCheckpoint("d1", "p2"); cp != nil {
+		t.Fatalf("load a missing pump: %+v", cp)
+	}
+}
+
+// testConcurrentSaves saves the checkpoints of many consumers of the same ID at
+// once, the one saved at last must be the furthest whatever order they land
+func testConcurrentSaves(t *testing.T, s CheckpointStore) {
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.SaveCheckpoint("d", "p", off(0, int64(i)*8), int64(i))
+			if err != nil && err != ErrCheckpointRegressed {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cp, err := s.LoadCheckpoint("d", "p")
+	if err != nil || cp == nil || cp.Offset != off(0, n*8) || cp.CommitTs != n {
+		t.Fatalf("load after concurrent saves: %+v, %v", cp, err)
+	}
+}
+
+func TestEtcdCheckpointStore(t *testing.T) {
+	r := NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+	testCheckpointStore(t, r)
+}
+
+func TestEtcdCheckpointConflicts(t *testing.T) {
+	r := NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+	testConcurrentSaves(t, r)
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	path := t.TempDir() + "/checkpoint.json"
+	s, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckpointStore(t, s)
+
+	// reload the saved checkpoints
+	s, err = NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		consumerID string
+		offset     binlogscheme.BinlogOffset
+		commitTs   int64
+	}{
+		{"d1", off(2, 32), 7},
+		{"d2", off(0, 32), 1},
+	} {
+		cp, err := s.LoadCheckpoint(c.consumerID, "p1")
+		if err != nil || cp == nil || cp.Offset != c.offset || cp.CommitTs != c.commitTs {
+			t.Fatalf("reload %s: %+v, %v", c.consumerID, cp, err)
+		}
+	}
+}
+
+func TestFileCheckpointConcurrentSaves(t *testing.T) {
+	s, err := NewFileCheckpointStore(t.TempDir() + "/checkpoint.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testConcurrentSaves(t, s)
+}
+
+func TestFileCheckpointCorrupted(t *testing.T) {
+	path := t.TempDir() + "/checkpoint.json"
+	if err := ioutil.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCheckpointStore(path); err == nil {
+		t.Fatal("load a corrupted checkpoint file")
+	}
+}
@@ -8,19 +8,21 @@ import (
 	"golang.org/x/net/context"
 )
 
-type EctdRegistry struct {
-	client		*etcd.Etcd
-	reqTimeout	time.Duration
+// EtcdRegistry keeps the machines, the window board and
+// the checkpoints of the consumers in etcd
+type EtcdRegistry struct {
+	client     *etcd.Etcd
+	reqTimeout time.Duration
 }
 
-func NewEtcdRegistry(client *etcd.Etcd, reqTimeout time.Duration) Registry {
-	return &EtcdRegistry {
-		client:		client,
-		reqTimeout:	reqTimeout,
+func NewEtcdRegistry(client *etcd.Etcd, reqTimeout time.Duration) *EtcdRegistry {
+	return &EtcdRegistry{
+		client:     client,
+		reqTimeout: reqTimeout,
 	}
 }
 
-func (r *EtcdRegistry) ctx() (context.Context, context.cancelFunc) {
+func (r *EtcdRegistry) ctx() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithTimeout(context.Background(), r.reqTimeout)
 	return ctx, cancel
 }
@@ -28,8 +30,3 @@ func (r *EtcdRegistry) ctx() (context.Context, context.cancelFunc) {
 func (r *EtcdRegistry) prefixed(p ...string) string {
 	return path.Join(p...)
 }
-
-func isEtcdError(err error, code int) bool {
-	eerr, ok := err.(etcd.Error)
-	return ok && eerr.Code == code
-}
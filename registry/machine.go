@@ -1,37 +1,32 @@
 package registry
 
 import (
-	"path"
-	"fmt"
 	"errors"
+	"fmt"
 
 	"github.com/ngaut/log"
-	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
 	"github.com/pingcap/tidb-binlog/machine"
+	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
 )
 
-const machiinePrefix = "machine"
+const machinePrefix = "machine"
 
-type node struct {
-	child map[string] *node
-}
-
-func (r *EtcdRegistry) Machine(machineID string) (*machine.MachineStatus, error) {
+func (r *EtcdRegistry) Machine(machID string) (*machine.MachineStatus, error) {
 	ctx, cancel := r.ctx()
 	defer cancel()
-	resp, err := r.client.List(ctx, path.Join(machinePrefix, machineID))
+	resp, err := r.client.List(ctx, r.prefixed(machinePrefix, machID))
 	if err != nil {
-		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
-			e := fmt.Sprintf("Machine not found in etcd, machID: %s, %v", machID, err)
-			log.Error(e)
-			return nil, errors.New(e)
-                }
 		return nil, err
 	}
+	if len(resp.Childs) == 0 {
+		e := fmt.Sprintf("Machine not found in etcd, machID: %s", machID)
+		log.Error(e)
+		return nil, errors.New(e)
+	}
 
-	status, err := machineStatusFromEtcdNode(machineID, resp)
+	status, err := machineStatusFromEtcdNode(machID, resp)
 	if err != nil || status == nil {
-		e := errors.New(fmt.Sprintf("Invalid machine node, machID[%s], error[%v]", machineID, err))
+		e := errors.New(fmt.Sprintf("Invalid machine node, machID[%s], error[%v]", machID, err))
 		return nil, e
 	}
 	return status, nil
@@ -42,17 +37,14 @@ func (r *EtcdRegistry) Machines() (map[string]*machine.MachineStatus, error) {
 	defer cancel()
 	resp, err := r.client.List(ctx, machinePrefix)
 	if err != nil {
-		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
-			e := errors.New(fmt.Sprintf("%s not found in etcd, cluster may not be properly bootstrapped", key))
-			return nil, e
-                }
 		return nil, err
 	}
+
 	IDToMachine := make(map[string]*machine.MachineStatus)
 	for machID, node := range resp.Childs {
 		status, err := machineStatusFromEtcdNode(machID, node)
 		if err != nil || status == nil {
-			e := errors.New(fmt.Sprintf("Invalid machine node, machID[%s], error[%v]", node.Key, err))
+			e := errors.New(fmt.Sprintf("Invalid machine node, machID[%s], error[%v]", machID, err))
 			return nil, e
 		}
 		IDToMachine[machID] = status
@@ -64,10 +56,10 @@ func machineStatusFromEtcdNode(machID string, node *etcd.Node) (*machine.Machine
 	status := &machine.MachineStatus{
 		MachID: machID,
 	}
-	for key, n := range node.Childs  {
+	for key, n := range node.Childs {
 		switch key {
 		case "object":
-			if err := unmarshal(n.Value, &status.MachInfo); err != nil {
+			if err := unmarshal(string(n.Value), &status.MachInfo); err != nil {
 				log.Errorf("Error unmarshaling MachInfo, machID: %s, %v", machID, err)
 				return nil, err
 			}
@@ -78,7 +70,7 @@ func machineStatusFromEtcdNode(machID string, node *etcd.Node) (*machine.Machine
 	return status, nil
 }
 
-func (r *EtcdRegistry) RegisterMachine(machID, hostName,  publicIP string) error {
+func (r *EtcdRegistry) RegisterMachine(machID, hostName, publicIP string) error {
 	if exists, err := r.checkMachineExists(machID); err != nil {
 		return err
 	} else if !exists {
@@ -88,8 +80,8 @@ func (r *EtcdRegistry) RegisterMachine(machID, hostName,  publicIP string) error
 
 	// found it, update host infomation of the machine
 	machInfo := &machine.MachineInfo{
-		HostName:   hostName,
-		PublicIP:   publicIP,
+		HostName: hostName,
+		PublicIP: publicIP,
 	}
 	return r.updateMeachineInfo(machID, machInfo)
 }
@@ -97,9 +89,9 @@ func (r *EtcdRegistry) RegisterMachine(machID, hostName,  publicIP string) error
 func (r *EtcdRegistry) checkMachineExists(machID string) (bool, error) {
 	ctx, cancel := r.ctx()
 	defer cancel()
-	_, err := r.client.Get(ctx, r.prefixed(machinePrefix, machID))
+	_, err := r.client.Get(ctx, r.prefixed(machinePrefix, machID, "object"))
 	if err != nil {
-		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		if etcd.IsNotFound(err) {
 			return false, nil
 		}
 		return false, err
@@ -117,7 +109,7 @@ func (r *EtcdRegistry) updateMeachineInfo(machID string, machInfo *machine.Machi
 	ctx, cancel := r.ctx()
 	defer cancel()
 	key := r.prefixed(machinePrefix, machID, "object")
-	if _, err := r.client.Update(ctx, key, object, false, 0); err != nil {
+	if err := r.client.Update(ctx, key, object, 0); err != nil {
 		e := fmt.Sprintf("Failed to update MachInfo in etcd, %s, %v, %v", machID, object, err)
 		log.Error(e)
 		return errors.New(e)
@@ -125,14 +117,16 @@ func (r *EtcdRegistry) updateMeachineInfo(machID string, machInfo *machine.Machi
 	return nil
 }
 
-func (r *EtcdRegistry) createMachine(machID, hostName,  publicIP string) error {
+func (r *EtcdRegistry) createMachine(machID, hostName, publicIP string) error {
 	object := &machine.MachineInfo{
-		HostName:   hostName,
-		PublicIP:   publicIP,
+		HostName: hostName,
+		PublicIP: publicIP,
 	}
-	
+
 	if objstr, err := marshal(object); err == nil {
-		if err := r.client.create(r.prefixed(machinePrefix, machID, "object"), objstr); err != nil {
+		ctx, cancel := r.ctx()
+		defer cancel()
+		if err := r.client.Create(ctx, r.prefixed(machinePrefix, machID, "object"), objstr); err != nil {
 			e := fmt.Sprintf("Failed to create MachInfo of machine node, %s, %v, %v", machID, object, err)
 			log.Error(e)
 			return errors.New(e)
@@ -146,11 +140,9 @@ func (r *EtcdRegistry) createMachine(machID, hostName,  publicIP string) error {
 	return nil
 }
 
-func (r *EtcdRegistry) RefreshMachine(machID string, machStat machine.MachineStat, ttl int64) error {
-	if err := r.refreshMachineAlive(machID, ttl); err != nil {
-		return nil
-	}
-	return nil
+// RefreshMachine keeps the machine alive for ttl seconds
+func (r *EtcdRegistry) RefreshMachine(machID string, ttl int64) error {
+	return r.refreshMachineAlive(machID, ttl)
 }
 
 func (r *EtcdRegistry) refreshMachineAlive(machID string, ttl int64) error {
@@ -158,7 +150,7 @@ func (r *EtcdRegistry) refreshMachineAlive(machID string, ttl int64) error {
 	// try to touch alive state of machine, update ttl
 	ctx, cancel := r.ctx()
 	defer cancel()
-	if _, err := r.client.Update(ctx, aliveKey, "", ttl); err != nil {
+	if err := r.client.Update(ctx, aliveKey, "", ttl); err != nil {
 		return err
 	}
 	return nil
@@ -1,45 +1,138 @@
 package registry
 
 import (
-	"fmt"
-	"errors"
 	"strconv"
 
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb-binlog/machine"
 	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
 )
 
-const WindowBoardPrefix = "windowBoard"
+// the window board is the CommitTs up to which every live pump has synced
+// all its entries, so the drainers can emit the entries below it in order
+// without waiting for any pump. every pump publishes the CommitTs up to
+// which it has synced, held below its writes in flight, under pumpTsPrefix,
+// an idle one publishes it ahead of its last entry, and the leader of the
+// pumps moves the board to the lowest of them. both only move forward,
+// guarded by compare and swap, so a stale leader or pump can't move them
+// backward. a pump still takes a late write below the board
+const (
+	WindowBoardPrefix = "windowBoard"
+	pumpTsPrefix      = "pumpTs"
+)
 
+// GetWindowBoard returns 0 if the board has never been advanced
 func (r *EtcdRegistry) GetWindowBoard() (int64, error) {
 	ctx, cancel := r.ctx()
 	defer cancel()
-	resp, err := r.client.Get(ctx, WindowBoardPrefix)
+	val, err := r.client.Get(ctx, WindowBoardPrefix)
+	if etcd.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+// UpdateWindowBoard moves the board forward to board, it returns the board
+// after the update, which is the saved one if it's not below board
+func (r *EtcdRegistry) UpdateWindowBoard(board int64) (int64, error) {
+	return r.advance(WindowBoardPrefix, board)
+}
+
+// UpdatePumpTs publishes the durable CommitTs of the pump,
+// it's ignored if the pump has published a higher one
+func (r *EtcdRegistry) UpdatePumpTs(pumpID string, ts int64) error {
+	_, err := r.advance(r.prefixed(pumpTsPrefix, pumpID), ts)
+	return err
+}
+
+// PumpTs returns the published CommitTs of every pump
+func (r *EtcdRegistry) PumpTs() (map[string]int64, error) {
+	ctx, cancel := r.ctx()
+	defer cancel()
+	resp, err := r.client.List(ctx, pumpTsPrefix)
+	if etcd.IsNotFound(err) {
+		return map[string]int64{}, nil
+	}
 	if err != nil {
-		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
-			// not found
-			e := fmt.Sprintf("Window Board not found in etcd, %s, err)
-			log.Error(e)
-			return nil, errors.New(e)
-		}
 		return nil, err
 	}
 
-	board, err := strconv.Atoi(string(resp))
+	pumpTs := make(map[string]int64, len(resp.Childs))
+	for pumpID, node := range resp.Childs {
+		ts, err := strconv.ParseInt(string(node.Value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		pumpTs[pumpID] = ts
+	}
+	return pumpTs, nil
+}
+
+// AdvanceWindowBoard moves the board to the lowest CommitTs published by
+// the live machines, it's called by the leader of the pumps. the board is
+// kept if one of them hasn't published yet. it returns the board after it
+func (r *EtcdRegistry) AdvanceWindowBoard(machines map[string]*machine.MachineStatus) (int64, error) {
+	pumpTs, err := r.PumpTs()
 	if err != nil {
 		return 0, err
 	}
 
-	return int64(board), nil
+	var (
+		board int64
+		found bool
+	)
+	for machID, m := range machines {
+		if !m.IsAlive {
+			continue
+		}
+
+		ts, ok := pumpTs[machID]
+		if !ok {
+			log.Infof("pump %s hasn't published its CommitTs, the window board is kept", machID)
+			return r.GetWindowBoard()
+		}
+		if !found || ts < board {
+			board, found = ts, true
+		}
+	}
+
+	if !found {
+		return r.GetWindowBoard()
+	}
+	return r.UpdateWindowBoard(board)
 }
 
-func (r *EtcdRegistry) UpdateWindowBoard(board int64) error {
+// advance compares and swaps the int64 of the key until it's not below
+// ts, it returns the value after it
+func (r *EtcdRegistry) advance(key string, ts int64) (int64, error) {
 	ctx, cancel := r.ctx()
 	defer cancel()
-	boardStr := fmt.Sprintf("%d", board)
-	if _, err := r.client.Update(ctx, WindowBoard, boardStr, 0); err != nil {
-		e := fmt.Sprintf("Failed to update Window Board in etcd %v, %v"object, err)
-		log.Error(e)
-		return errors.New(e)
+	for {
+		val, revision, err := r.client.GetWithRevision(ctx, key)
+		if err != nil && !etcd.IsNotFound(err) {
+			return 0, err
+		}
+
+		if err == nil {
+			saved, err := strconv.ParseInt(string(val), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			if saved >= ts {
+				return saved, nil
+			}
+		}
+
+		err = r.client.CompareAndSwap(ctx, key, strconv.FormatInt(ts, 10), revision)
+		if err == nil {
+			return ts, nil
+		}
+		if !etcd.IsRevisionConflict(err) {
+			return 0, err
+		}
 	}
-	return nil
 }
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-binlog/machine"
+	"github.com/pingcap/tidb-binlog/util/etcdutil/etcdtest"
+)
+
+func TestWindowBoard(t *testing.T) {
+	r := NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+	if board, err := r.GetWindowBoard(); board != 0 || err != nil {
+		t.Fatalf("board of nothing published %d, %v", board, err)
+	}
+
+	machines := map[string]*machine.MachineStatus{
+		"a": {MachID: "a", IsAlive: true},
+		"b": {MachID: "b", IsAlive: true},
+		"c": {MachID: "c", IsAlive: false},
+	}
+	mustUpdate := func(pumpID string, ts int64) {
+		if err := r.UpdatePumpTs(pumpID, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	advance := func(want int64) {
+		t.Helper()
+		if board, err := r.AdvanceWindowBoard(machines); board != want || err != nil {
+			t.Fatalf("board %d, %v, want %d", board, err, want)
+		}
+	}
+
+	// b hasn't published
+	mustUpdate("a", 10)
+	advance(0)
+
+	// the gone c doesn't count
+	mustUpdate("b", 7)
+	mustUpdate("c", 1)
+	advance(7)
+
+	// neither moves backward
+	mustUpdate("b", 5)
+	if pumpTs, err := r.PumpTs(); err != nil || len(pumpTs) != 3 || pumpTs["b"] != 7 {
+		t.Fatalf("pump ts %v, %v", pumpTs, err)
+	}
+	if board, err := r.UpdateWindowBoard(3); board != 7 || err != nil {
+		t.Fatalf("board moved back to %d, %v", board, err)
+	}
+
+	mustUpdate("b", 20)
+	advance(10)
+}
+
+// TestAdvanceRace moves the same values from many goroutines at once,
+// they must end at the highest whatever order the swaps land in
+func TestAdvanceRace(t *testing.T) {
+	r := NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+
+	const n = 16
+	var wg sync.WaitGroup
+	errc := make(chan error, 3*n)
+	for i := 1; i <= n; i++ {
+		wg.Add(3)
+		go func(ts int64) {
+			defer wg.Done()
+			if _, err := r.UpdateWindowBoard(ts); err != nil {
+				errc <- err
+			}
+		}(int64(i))
+		go func(ts int64) {
+			defer wg.Done()
+			errc <- r.UpdatePumpTs("a", ts)
+		}(int64(i * 10))
+		go func(ts int64) {
+			defer wg.Done()
+			errc <- r.UpdatePumpTs("b", ts)
+		}(int64(n*10 + 1 - i))
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if board, err := r.GetWindowBoard(); board != n || err != nil {
+		t.Fatalf("board %d, %v, want %d", board, err, n)
+	}
+	pumpTs, err := r.PumpTs()
+	if err != nil || pumpTs["a"] != n*10 || pumpTs["b"] != n*10 {
+		t.Fatalf("pump ts %v, %v", pumpTs, err)
+	}
+}
+
+// TestAdvanceWindowBoardRace advances the board from several leaders while
+// the pumps publish, the board never moves backward and ends at the lowest
+func TestAdvanceWindowBoardRace(t *testing.T) {
+	r := NewEtcdRegistry(etcdtest.NewEtcd(t, "/tidb-binlog"), 5*time.Second)
+	machines := make(map[string]*machine.MachineStatus)
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("pump-%d", i)
+		machines[id] = &machine.MachineStatus{MachID: id, IsAlive: true}
+	}
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	errc := make(chan error, 64)
+	for id := range machines {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for ts := int64(1); ts <= rounds; ts++ {
+				if err := r.UpdatePumpTs(id, ts); err != nil {
+					errc <- err
+					return
+				}
+			}
+		}(id)
+	}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last int64
+			for j := 0; j < rounds; j++ {
+				board, err := r.AdvanceWindowBoard(machines)
+				if err != nil {
+					errc <- err
+					return
+				}
+				if board < last {
+					errc <- fmt.Errorf("board moves backward from %d to %d", last, board)
+					return
+				}
+				last = board
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Fatal(err)
+	}
+
+	if board, err := r.AdvanceWindowBoard(machines); board != rounds || err != nil {
+		t.Fatalf("board %d, %v, want %d", board, err, rounds)
+	}
+}
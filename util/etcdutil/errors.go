@@ -3,33 +3,42 @@ package etcdutil
 import "fmt"
 
 const (
-	ErrCodeNotFound int = iota + 1
+	ErrCodeKeyNotFound int = iota + 1
 	ErrCodeKeyExists
+	ErrCodeRevisionConflict
 )
 
-var errCodeToMessage = map[int] string {
-	ErrCodeKeyNotFound:	"key not found",
-	ErrCodeKeyExists:	"key exists",
+var errorCodeToMessage = map[int]string{
+	ErrCodeKeyNotFound:      "key not found",
+	ErrCodeKeyExists:        "key exists",
+	ErrCodeRevisionConflict: "revision conflict",
 }
 
-func NewKeyNotFoundError(key string) *RegistryError {
-	return &StorageError{
-		Code:            ErrCodeKeyNotFound,
-		Key:             key,
+func NewKeyNotFoundError(key string) *Error {
+	return &Error{
+		Code: ErrCodeKeyNotFound,
+		Key:  key,
 	}
 }
 
-func NewKeyExistsError(key string) *RegistryError {
-	return &StorageError{
-		Code:            ErrCodeKeyExists,
-		Key:             key,
+func NewKeyExistsError(key string) *Error {
+	return &Error{
+		Code: ErrCodeKeyExists,
+		Key:  key,
+	}
+}
+
+func NewRevisionConflictError(key string) *Error {
+	return &Error{
+		Code: ErrCodeRevisionConflict,
+		Key:  key,
 	}
 }
 
 type Error struct {
-	Code			int
-	Key			string
-	AdditionalErrorMsg	string
+	Code               int
+	Key                string
+	AdditionalErrorMsg string
 }
 
 func (r *Error) Error() string {
@@ -45,12 +54,17 @@ func IsNotExist(err error) bool {
 	return isErrCode(err, ErrCodeKeyExists)
 }
 
+// IsRevisionConflict returns whether the key was changed by someone else
+func IsRevisionConflict(err error) bool {
+	return isErrCode(err, ErrCodeRevisionConflict)
+}
+
 func isErrCode(err error, code int) bool {
 	if err == nil {
 		return false
 	}
 
-	if r, ok := err.(*RegistryError); ok {
+	if r, ok := err.(*Error); ok {
 		return r.Code == code
 	}
 
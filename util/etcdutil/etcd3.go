@@ -1,120 +1,119 @@
 package etcdutil
 
 import (
-	"time"
+	"path"
 	"strings"
+	"time"
 
+	"go.etcd.io/etcd/client/v3"
 	"golang.org/x/net/context"
-	"github.com/ngaut/log"
-	"github.com/coreos/etcd/clientv3"
 )
 
+// Node is a key of etcd with the keys under it, which are split by "/"
 type Node struct {
 	Value  []byte
-	Childs map[string] *Node	
+	Childs map[string]*Node
 }
 
+// Etcd is a client of etcd with all the keys under pathPrefix
 type Etcd struct {
-	client 		*clientv3.Client
-	pathPrefix	string
-	reqTimeout	time.Duration
-	etcdAddrs	string
-	ttl		int64
+	client     *clientv3.Client
+	pathPrefix string
+	reqTimeout time.Duration
+	etcdAddrs  string
 }
 
 func NewEtcd(client *clientv3.Client, pathPrefix string, reqTimeout time.Duration, etcdAddrs string) *Etcd {
-	return &Etcd {
-		client:		client,
-		pathPrefix:	pathPrefix,
-		reqTimeout:	reqTimeout,
-		etcdddrs:	etcdAddrs,
+	return &Etcd{
+		client:     client,
+		pathPrefix: pathPrefix,
+		reqTimeout: reqTimeout,
+		etcdAddrs:  etcdAddrs,
 	}
 }
 
-func (e *Ectd) Create(ctx context.Context, key string,  val string, opts ...clientv3.OpOption) error {
-	key = KeyWithPreix(e.pathPrefix, key)
-	txcResp, err := e.client.KV.Txn(ctx).If(
+// Create puts the key only if it doesn't exist,
+// it returns a key exists error otherwise
+func (e *Etcd) Create(ctx context.Context, key string, val string, opts ...clientv3.OpOption) error {
+	key = keyWithPrefix(e.pathPrefix, key)
+	txnResp, err := e.client.KV.Txn(ctx).If(
 		notFound(key),
 	).Then(
-		clientv3.OpPut(key, val, opts...)
+		clientv3.OpPut(key, val, opts...),
 	).Commit()
 	if err != nil {
 		return err
 	}
 
 	if !txnResp.Succeeded {
-		return KeyExistsError(key)
+		return NewKeyExistsError(key)
 	}
 
 	return nil
 }
 
 func (e *Etcd) Get(ctx context.Context, key string) ([]byte, error) {
-	key = KeyWithPrefix(e.pathPrefix, key)
-	resp, err := e.client.KV.GET(ctx, key)
+	val, _, err := e.GetWithRevision(ctx, key)
+	return val, err
+}
+
+// GetWithRevision returns the value of the key with its mod revision,
+// which is passed to CompareAndSwap to update the value read
+func (e *Etcd) GetWithRevision(ctx context.Context, key string) ([]byte, int64, error) {
+	key = keyWithPrefix(e.pathPrefix, key)
+	resp, err := e.client.KV.Get(ctx, key)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(resp.Kvs) == 0 {
-		return nil, KeyNotFoundError(key)
-	} 
+		return nil, 0, NewKeyNotFoundError(key)
+	}
 
-	return resp.Kvs[0].Value, nil
+	return resp.Kvs[0].Value, resp.Kvs[0].ModRevision, nil
 }
 
-func (e *Etcd) Update(ctx context.Context, key string, val string, ttl int64)  error {
+// CompareAndSwap sets the key only if its mod revision is still revision,
+// a revision of 0 means that the key must not exist. it returns a revision
+// conflict error if the key was changed in between
+func (e *Etcd) CompareAndSwap(ctx context.Context, key string, val string, revision int64) error {
 	key = keyWithPrefix(e.pathPrefix, key)
-	
-	opts := nil
-	if  ttl > 0 {
-		lcr, err := e.client.Lease.Grant(ctx, ttl)
-		if err != nil {
-			return err
-		}
-
-		opts = []clientv3.OpOption{clientv3.WithLease(clientv3.LeaseID(lcr.ID))}
-	}
-	
-	getResp, err := e.client.KV.GET(ctx, key)
+	txnResp, err := e.client.KV.Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(key), "=", revision),
+	).Then(
+		clientv3.OpPut(key, val),
+	).Commit()
 	if err != nil {
-		return nil
+		return err
 	}
 
-	originRevision := 0
-
-	if len(getResp.Kvs) == 0 {
-		err = s.Create(ctx, key, val, opts)
-		if err != nil {
-			return err
-		}
-	} else {
-		originRevision = getResp.Kvs[0].ModRevision
+	if !txnResp.Succeeded {
+		return NewRevisionConflictError(key)
 	}
 
-	for {
-		txnResp, err := e.client.KV.Txn(ctx).IF(
-			clientv3.Compare(clientv3.ModRevision(key) , "=", originRevision),
-		).Then(
-			clientv3.OpPUT(key, val, opts...),
-		).Else(
-			clientv3.OpGet(key)
-		).Commit()
+	return nil
+}
+
+// Update sets the key whatever it is, the key is deleted
+// after ttl seconds if ttl is positive
+func (e *Etcd) Update(ctx context.Context, key string, val string, ttl int64) error {
+	key = keyWithPrefix(e.pathPrefix, key)
+
+	var opts []clientv3.OpOption
+	if ttl > 0 {
+		lcr, err := e.client.Lease.Grant(ctx, ttl)
 		if err != nil {
 			return err
 		}
 
-		if !txnResp.Succeeded {
-			getResp = (*clientv3.GetResponse)(txnResp.Responses[0].GetResponseRange())
-			originRevision = getResp.Kvs[0].ModRevision
-			glog.Infof("Update of %s failed because of a conflict, going to retry", key)
-			continue
-		}
+		opts = []clientv3.OpOption{clientv3.WithLease(lcr.ID)}
 	}
 
-	return nil
+	_, err := e.client.KV.Put(ctx, key, val, opts...)
+	return err
 }
 
+// List returns the keys under the key as a tree
 func (e *Etcd) List(ctx context.Context, key string) (*Node, error) {
 	key = keyWithPrefix(e.pathPrefix, key)
 	if !strings.HasSuffix(key, "/") {
@@ -126,14 +125,14 @@ func (e *Etcd) List(ctx context.Context, key string) (*Node, error) {
 		return nil, err
 	}
 
-	root = new(Node)
+	root := new(Node)
 	length := len(key)
-	for i, kv := range resp.Kvs {
+	for _, kv := range resp.Kvs {
 		key := string(kv.Key)
 		if len(key) <= length {
 			continue
 		}
-		
+
 		keyTail := key[length:]
 		tailNode := parseToDirTree(root, keyTail)
 		tailNode.Value = kv.Value
@@ -142,26 +141,26 @@ func (e *Etcd) List(ctx context.Context, key string) (*Node, error) {
 	return root, nil
 }
 
-func parseToDirTree(root *Node, path string) *Node  {
-	pathDirs := string.Split(path)
+func parseToDirTree(root *Node, path string) *Node {
+	pathDirs := strings.Split(path, "/")
 	current := root
-	var next *Node 
+	var next *Node
 	var ok bool
 
-        for _, dir :=  range pathDirs {
-        	if current.Childs == nil {
-                	current.Childs = make(map[string] *Node)
-                }
+	for _, dir := range pathDirs {
+		if current.Childs == nil {
+			current.Childs = make(map[string]*Node)
+		}
 
-               	next , ok = current.Childs[dir]
+		next, ok = current.Childs[dir]
 		if !ok {
 			current.Childs[dir] = new(Node)
-			next = current.Childs[dir] 
+			next = current.Childs[dir]
 		}
-		
+
 		current = next
-       	}
-	
+	}
+
 	return current
 }
 
@@ -170,7 +169,7 @@ func notFound(key string) clientv3.Cmp {
 }
 
 func keyWithPrefix(prefix, key string) string {
-	if strings.HashPrefix(key, prefix) {
+	if strings.HasPrefix(key, prefix) {
 		return key
 	}
 
@@ -0,0 +1,83 @@
+package etcdutil_test
+
+import (
+	"testing"
+
+	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
+	"github.com/pingcap/tidb-binlog/util/etcdutil/etcdtest"
+	"golang.org/x/net/context"
+)
+
+func TestCreateAndGet(t *testing.T) {
+	e := etcdtest.NewEtcd(t, "/test")
+	ctx := context.Background()
+
+	if _, err := e.Get(ctx, "a"); !etcd.IsNotFound(err) {
+		t.Fatalf("get a missing key: %v", err)
+	}
+	if err := e.Create(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Create(ctx, "a", "2"); !etcd.IsNotExist(err) {
+		t.Fatalf("create an existing key: %v", err)
+	}
+	if val, err := e.Get(ctx, "a"); err != nil || string(val) != "1" {
+		t.Fatalf("get a: %q, %v", val, err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	e := etcdtest.NewEtcd(t, "/test")
+	ctx := context.Background()
+
+	if _, _, err := e.GetWithRevision(ctx, "a"); !etcd.IsNotFound(err) {
+		t.Fatalf("get a missing key: %v", err)
+	}
+	// revision 0 only creates the key
+	if err := e.CompareAndSwap(ctx, "a", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CompareAndSwap(ctx, "a", "1", 0); !etcd.IsRevisionConflict(err) {
+		t.Fatalf("create an existing key: %v", err)
+	}
+
+	_, rev, err := e.GetWithRevision(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Update(ctx, "a", "2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.CompareAndSwap(ctx, "a", "3", rev); !etcd.IsRevisionConflict(err) {
+		t.Fatalf("swap with a stale revision: %v", err)
+	}
+
+	_, rev, _ = e.GetWithRevision(ctx, "a")
+	if err = e.CompareAndSwap(ctx, "a", "3", rev); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := e.Get(ctx, "a"); string(val) != "3" {
+		t.Fatalf("a is %q, want 3", val)
+	}
+}
+
+func TestList(t *testing.T) {
+	e := etcdtest.NewEtcd(t, "/test")
+	ctx := context.Background()
+
+	e.Update(ctx, "machine/m1/object", "o1", 0)
+	e.Update(ctx, "machine/m1/alive", "", 0)
+	e.Update(ctx, "machine/m2/object", "o2", 0)
+
+	root, err := e.List(ctx, "machine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Childs) != 2 {
+		t.Fatalf("list %d machines, want 2", len(root.Childs))
+	}
+	m1 := root.Childs["m1"]
+	if string(m1.Childs["object"].Value) != "o1" || m1.Childs["alive"] == nil {
+		t.Fatalf("machine m1 is %+v", m1.Childs)
+	}
+}
@@ -0,0 +1,50 @@
+// Package etcdtest starts an embedded etcd for the tests of the packages keeping their state in etcd
+package etcdtest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	etcd "github.com/pingcap/tidb-binlog/util/etcdutil"
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// NewEtcd starts a single member etcd in a temporary directory and returns a client of it,
+// which are both closed when the test finishes
+func NewEtcd(t testing.TB, pathPrefix string) *etcd.Etcd {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	lpurl, _ := url.Parse("http://127.0.0.1:0")
+	lcurl, _ := url.Parse("http://127.0.0.1:0")
+	cfg.ListenPeerUrls = []url.URL{*lpurl}
+	cfg.ListenClientUrls = []url.URL{*lcurl}
+	cfg.AdvertisePeerUrls = cfg.ListenPeerUrls
+	cfg.AdvertiseClientUrls = cfg.ListenClientUrls
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("etcd takes too long to start")
+	}
+
+	addr := e.Clients[0].Addr().String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{addr},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return etcd.NewEtcd(client, pathPrefix, 5*time.Second, addr)
+}
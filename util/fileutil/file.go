@@ -40,7 +40,7 @@ func ReadDir(dirpath string) ([]string, error) {
 }
 
 func TouchDirAll(dir string) error {
-	if err := os.MkdirAll(dir); err  != nil {
+	if err := os.MkdirAll(dir, PrivateDirMode); err != nil {
 		return err
 	}
 
@@ -60,6 +60,7 @@ func CreateDirAll(dir string) error {
 	if len(ns) != 0 {
 		return fmt.Errorf("expected %q to be empty, got %q", dir, ns)
 	}
+	return nil
 }
 
 func Exist(name string) bool {
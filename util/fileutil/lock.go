@@ -3,6 +3,7 @@ package fileutil
 import (
 	"errors"
 	"os"
+	"syscall"
 )
 
 var (
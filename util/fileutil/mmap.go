@@ -0,0 +1,18 @@
+//go:build unix
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// Mmap maps the first size bytes of f read-only and shared,
+// the mapping stays valid after f is closed
+func Mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func Munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
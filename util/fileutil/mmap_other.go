@@ -0,0 +1,20 @@
+//go:build !unix
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapNotSupported = errors.New("fileutil: mmap is not supported on this platform")
+
+// Mmap always fails where mmap is not supported,
+// the callers fall back to reading through the file
+func Mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapNotSupported
+}
+
+func Munmap(b []byte) error {
+	return errMmapNotSupported
+}
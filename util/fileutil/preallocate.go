@@ -9,16 +9,16 @@ func Preallocate(f *os.File, sizeInBytes int64, extendFile bool) error {
 	if extendFile {
 		return preallocExtend(f, sizeInBytes)
 	}
-	
+
 	return preallocFixed(f, sizeInBytes)
 }
 
 func preallocExtend(f *os.File, sizeInBytes int64) error {
-	err := syscall.Fallocate(int(f.FD()), 0, 0, sizeInBytes)
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, sizeInBytes)
 	if err != nil {
 		errno, ok := err.(syscall.Errno)
 		if ok && (errno == syscall.ENOTSUP || errno == syscall.EINTR) {
-			return preallocExtendTrunc(f. sizeInBytes)
+			return preallocExtendTrunc(f, sizeInBytes)
 		}
 	}
 
@@ -26,7 +26,7 @@ func preallocExtend(f *os.File, sizeInBytes int64) error {
 }
 
 func preallocFixed(f *os.File, sizeInBytes int64) error {
-	err := syscall.Fallocate(int(f.FD()), 1, 0, sizeInBytes)
+	err := syscall.Fallocate(int(f.Fd()), 1, 0, sizeInBytes)
 	if err != nil {
 		errno, ok := err.(syscall.Errno)
 		if ok && errno == syscall.ENOTSUP {
@@ -38,12 +38,12 @@ func preallocFixed(f *os.File, sizeInBytes int64) error {
 }
 
 func preallocExtendTrunc(f *os.File, sizeInBytes int64) error {
-	curOff, err := f.seek(0, os.SEEK_CUR)
+	curOff, err := f.Seek(0, os.SEEK_CUR)
 	if err != nil {
 		return err
 	}
 
-	size, err := f.Seek(sizeInByte, os.SEEK_END)
+	size, err := f.Seek(sizeInBytes, os.SEEK_END)
 	if err != nil {
 		return err
 	}
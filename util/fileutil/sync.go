@@ -1,4 +1,4 @@
-packafe fileutil
+package fileutil
 
 import "os"
 